@@ -0,0 +1,227 @@
+/*
+Package audit provides an append-only log of actions that cause chaos, such as
+triggering chaos events or wiping the state of Chaos Monkey.
+
+The log is stored locally as JSON lines, one Entry per line. Entries can
+additionally be sent to syslog.
+*/
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/mlafeldt/chaosmonkey/aws"
+	"github.com/mlafeldt/chaosmonkey/internal/xdg"
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+)
+
+// Commands recorded in the audit log
+const (
+	CommandTrigger   = "trigger"
+	CommandWipeState = "wipe-state"
+	CommandRollback  = "rollback"
+)
+
+// Entry describes a single action recorded in the audit log.
+type Entry struct {
+	// Time when the action was performed
+	Time time.Time
+
+	// Command that was run, e.g. CommandTrigger
+	Command string
+
+	// Local user and host running the command
+	Operator string
+	Host     string
+
+	// AWS identity of the operator, if known
+	AWSIdentity *aws.CallerIdentity `json:",omitempty"`
+
+	// Chaos Monkey API server and AWS region
+	Endpoint string `json:",omitempty"`
+	Region   string `json:",omitempty"`
+
+	// Name of the experiment, if any
+	Experiment string `json:",omitempty"`
+
+	// Auto scaling group and chaos strategy of triggered chaos events
+	Group    string               `json:",omitempty"`
+	Strategy chaosmonkey.Strategy `json:",omitempty"`
+
+	// SimpleDB domain deleted by CommandWipeState
+	Domain string `json:",omitempty"`
+
+	// Resulting chaos event or error
+	Event *chaosmonkey.Event `json:",omitempty"`
+	Error string             `json:",omitempty"`
+}
+
+// Log is an append-only audit log.
+type Log struct {
+	file   *os.File
+	syslog *syslog.Writer
+}
+
+// DefaultPath returns the default location of the audit log, which is in the
+// user's state directory as defined by the XDG Base Directory Specification.
+func DefaultPath() string {
+	return xdg.StateFile("audit.log")
+}
+
+// Open opens the audit log at the given path for appending, creating it and
+// its directory if necessary. If useSyslog is true, entries are also sent to
+// the local syslog daemon.
+func Open(path string, useSyslog bool) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{file: f}
+	if useSyslog {
+		if l.syslog, err = syslog.New(syslog.LOG_NOTICE|syslog.LOG_USER, "chaosmonkey"); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Record appends the entry to the log. The time, operator, and host are filled
+// in if not set.
+func (l *Log) Record(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if e.Operator == "" {
+		e.Operator = currentUser()
+	}
+	if e.Host == "" {
+		e.Host, _ = os.Hostname()
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if l.syslog != nil {
+		return l.syslog.Notice(string(line))
+	}
+	return nil
+}
+
+// Close closes the log.
+func (l *Log) Close() error {
+	if l.syslog != nil {
+		l.syslog.Close()
+	}
+	return l.file.Close()
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// Query filters entries of the audit log. Empty fields match all entries.
+type Query struct {
+	// Glob pattern matching the auto scaling group, see path.Match
+	Group string
+
+	// Command and operator to match exactly
+	Command  string
+	Operator string
+
+	// Strategies to match
+	Strategies []chaosmonkey.Strategy
+
+	// Time window of entries, excluding Until
+	Since time.Time
+	Until time.Time
+
+	// Maximum number of entries to return, counting from the most recent one
+	Limit int
+}
+
+// Match reports whether the entry matches the query.
+func (q *Query) Match(e *Entry) bool {
+	if q.Group != "" {
+		if ok, _ := path.Match(q.Group, e.Group); !ok {
+			return false
+		}
+	}
+	if q.Command != "" && q.Command != e.Command {
+		return false
+	}
+	if q.Operator != "" && q.Operator != e.Operator {
+		return false
+	}
+	if len(q.Strategies) > 0 {
+		found := false
+		for _, s := range q.Strategies {
+			if s == e.Strategy {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !e.Time.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// Read returns the entries of the audit log at the given path that match the
+// query, oldest first.
+func Read(filename string, q Query) ([]Entry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", filename, n, err)
+		}
+		if q.Match(&e) {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[len(entries)-q.Limit:]
+	}
+	return entries, nil
+}
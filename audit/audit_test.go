@@ -0,0 +1,149 @@
+package audit_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/mlafeldt/chaosmonkey/audit"
+	"github.com/mlafeldt/chaosmonkey/aws"
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+)
+
+func TestLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state", "audit.log")
+
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	entries := []audit.Entry{
+		{
+			Time:        start,
+			Command:     audit.CommandTrigger,
+			Operator:    "alice",
+			Host:        "laptop",
+			AWSIdentity: &aws.CallerIdentity{Account: "123456789012", ARN: "arn:aws:iam::123456789012:user/alice"},
+			Endpoint:    "http://example.com:8080",
+			Group:       "web-asg",
+			Strategy:    chaosmonkey.StrategyShutdownInstance,
+			Event: &chaosmonkey.Event{
+				InstanceID:           "i-0123456789abcdef0",
+				AutoScalingGroupName: "web-asg",
+				Region:               "us-east-1",
+				Strategy:             chaosmonkey.StrategyShutdownInstance,
+				TriggeredAt:          start,
+			},
+		},
+		{
+			Time:     start.Add(time.Hour),
+			Command:  audit.CommandTrigger,
+			Operator: "bob",
+			Host:     "laptop",
+			Group:    "db-asg",
+			Strategy: chaosmonkey.StrategyBurnCPU,
+			Error:    "Auto scaling group db-asg cannot be found",
+		},
+		{
+			Time:     start.Add(2 * time.Hour),
+			Command:  audit.CommandWipeState,
+			Operator: "alice",
+			Host:     "laptop",
+			Domain:   "SIMIAN_ARMY",
+		},
+	}
+
+	// Reopen the log for every entry to make sure entries are appended
+	for _, e := range entries {
+		l, err := audit.Open(path, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Record(e); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []struct {
+		query audit.Query
+		want  []audit.Entry
+	}{
+		{audit.Query{}, entries},
+		{audit.Query{Group: "*-asg"}, entries[:2]},
+		{audit.Query{Command: audit.CommandWipeState}, entries[2:]},
+		{audit.Query{Operator: "alice"}, []audit.Entry{entries[0], entries[2]}},
+		{audit.Query{Strategies: []chaosmonkey.Strategy{chaosmonkey.StrategyBurnCPU}}, entries[1:2]},
+		{audit.Query{Since: start.Add(time.Hour), Until: start.Add(2 * time.Hour)}, entries[1:2]},
+		{audit.Query{Limit: 2}, entries[1:]},
+	}
+	for i, tt := range tests {
+		got, err := audit.Read(path, tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("%d. %s", i, diff)
+		}
+	}
+}
+
+func TestLogDefaults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	l, err := audit.Open(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Record(audit.Entry{Command: audit.CommandWipeState, Domain: "SIMIAN_ARMY"}); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	entries, err := audit.Read(path, audit.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("want 1 entry, got %d", len(entries))
+	}
+	if e := entries[0]; e.Time.IsZero() || e.Operator == "" {
+		t.Errorf("time and operator not filled in: %+v", e)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("want mode 0600, got %v", fi.Mode().Perm())
+	}
+
+	// Corrupt entries are reported with their line number
+	ioutil.WriteFile(path, []byte("{}\nnot json\n"), 0600)
+	if _, err := audit.Read(path, audit.Query{}); err == nil || !strings.Contains(err.Error(), "audit.log:2:") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	os.Setenv("XDG_STATE_HOME", "/tmp/state")
+	defer os.Unsetenv("XDG_STATE_HOME")
+	if got, want := audit.DefaultPath(), "/tmp/state/chaosmonkey/audit.log"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
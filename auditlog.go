@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/mlafeldt/chaosmonkey/audit"
+	"github.com/mlafeldt/chaosmonkey/aws"
+	"github.com/mlafeldt/chaosmonkey/experiment"
+)
+
+// auditRecorder records mutating commands in the audit log.
+type auditRecorder struct {
+	log *audit.Log
+
+	// Fields shared by all entries
+	base audit.Entry
+
+	// First error writing the audit log, see failure
+	mu  sync.Mutex
+	err error
+}
+
+// openAuditLog opens the audit log at the given path. It returns nil if path is
+// empty, i.e. auditing is disabled.
+func openAuditLog(path string, useSyslog bool, endpoint, region string) *auditRecorder {
+	if path == "" {
+		return nil
+	}
+	l, err := audit.Open(path, useSyslog)
+	if err != nil {
+		abort("failed to open audit log: %s", err)
+	}
+	a := &auditRecorder{log: l, base: audit.Entry{Endpoint: endpoint, Region: region}}
+	// The AWS identity is optional as the Chaos Monkey API doesn't need it
+	if id, err := aws.NewClient(region).CallerIdentity(); err == nil {
+		a.base.AWSIdentity = id
+	}
+	return a
+}
+
+// record adds the entry to the audit log.
+func (a *auditRecorder) record(e audit.Entry) error {
+	if a == nil {
+		return nil
+	}
+	e.AWSIdentity = a.base.AWSIdentity
+	if e.Endpoint == "" {
+		e.Endpoint = a.base.Endpoint
+	}
+	if e.Region == "" {
+		e.Region = a.base.Region
+	}
+	if e.Group == "" {
+		e.Group = a.base.Group
+	}
+	if e.Strategy == "" {
+		e.Strategy = a.base.Strategy
+	}
+	if err := a.log.Record(e); err != nil {
+		err = fmt.Errorf("failed to write audit log: %s", err)
+		a.mu.Lock()
+		if a.err == nil {
+			a.err = err
+		}
+		a.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// failure returns the first error writing the audit log, if any. As notifier
+// errors are only logged by the runner, it must be checked after a run.
+func (a *auditRecorder) failure() error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+// Notify implements experiment.Notifier, recording triggered chaos events and
+// failed runs.
+func (a *auditRecorder) Notify(ctx context.Context, n *experiment.Notification) error {
+	switch n.Type {
+	case experiment.EventTriggered:
+		return a.record(audit.Entry{
+			Command:    audit.CommandTrigger,
+			Experiment: n.Experiment,
+			Group:      n.Group,
+			Strategy:   n.Strategy,
+			Region:     n.Event.Region,
+			Event:      n.Event,
+		})
+	case experiment.RunFailed:
+		return a.record(audit.Entry{
+			Command:    audit.CommandTrigger,
+			Experiment: n.Experiment,
+			Error:      n.Error,
+		})
+	}
+	return nil
+}
+
+func (a *auditRecorder) close() {
+	if a != nil {
+		a.log.Close()
+	}
+}
@@ -3,6 +3,8 @@
 package aws
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -12,18 +14,40 @@ import (
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/simpledb"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
 	"github.com/aws/aws-sdk-go/service/sts"
 )
 
 // Client is a client to the AWS API.
 type Client struct {
 	Region string
+
+	// Optional endpoint of the AWS API, e.g. of a local stand-in such as
+	// LocalStack
+	Endpoint string
+
+	// Optional function called for every fault injected by
+	// TriggerEventContext, e.g. to record how to revert it
+	OnFault func(*Fault)
+
+	// Parameters of the scripts run via SSM for chaos strategies that
+	// would require SSH access with Simian Army
+	ScriptParams ScriptParams
+
+	// Clients of the AWS services used for chaos events, replaced by fakes
+	// in tests
+	ec2         ec2iface.EC2API
+	autoscaling autoscalingiface.AutoScalingAPI
+	ssm         ssmiface.SSMAPI
 }
 
-// NewClient returns a new Client.
+// NewClient returns a new Client. The endpoint of the AWS API can be
+// overridden via the environment variable AWS_ENDPOINT_URL.
 func NewClient(region string) *Client {
-	return &Client{Region: region}
+	return &Client{Region: region, Endpoint: os.Getenv("AWS_ENDPOINT_URL")}
 }
 
 // AutoScalingGroup describes an AWS auto scaling group.
@@ -33,6 +57,9 @@ type AutoScalingGroup struct {
 	DesiredCapacity    int
 	MinSize            int
 	MaxSize            int
+
+	// IDs of the instances in service
+	InstanceIDs []string
 }
 
 // AutoScalingGroups returns a list of all auto scaling groups.
@@ -46,19 +73,7 @@ func (c *Client) AutoScalingGroups() ([]AutoScalingGroup, error) {
 	var groups []AutoScalingGroup
 	err = svc.DescribeAutoScalingGroupsPages(nil, func(out *autoscaling.DescribeAutoScalingGroupsOutput, last bool) bool {
 		for _, g := range out.AutoScalingGroups {
-			inService := 0
-			for _, i := range g.Instances {
-				if aws.StringValue(i.LifecycleState) == autoscaling.LifecycleStateInService {
-					inService++
-				}
-			}
-			groups = append(groups, AutoScalingGroup{
-				Name:               aws.StringValue(g.AutoScalingGroupName),
-				InstancesInService: inService,
-				DesiredCapacity:    int(aws.Int64Value(g.DesiredCapacity)),
-				MinSize:            int(aws.Int64Value(g.MinSize)),
-				MaxSize:            int(aws.Int64Value(g.MaxSize)),
-			})
+			groups = append(groups, newAutoScalingGroup(g))
 		}
 		return !last
 	})
@@ -68,6 +83,112 @@ func (c *Client) AutoScalingGroups() ([]AutoScalingGroup, error) {
 	return groups, nil
 }
 
+// AutoScalingGroup returns the auto scaling group with the given name.
+func (c *Client) AutoScalingGroup(name string) (*AutoScalingGroup, error) {
+	return c.autoScalingGroup(context.Background(), name)
+}
+
+func (c *Client) autoScalingGroup(ctx context.Context, name string) (*AutoScalingGroup, error) {
+	svc, err := c.autoScalingAPI()
+	if err != nil {
+		return nil, err
+	}
+	out, err := svc.DescribeAutoScalingGroupsWithContext(ctx, &autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: []*string{aws.String(name)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.AutoScalingGroups) == 0 {
+		return nil, fmt.Errorf("auto scaling group %q does not exist", name)
+	}
+	g := newAutoScalingGroup(out.AutoScalingGroups[0])
+	return &g, nil
+}
+
+func newAutoScalingGroup(g *autoscaling.Group) AutoScalingGroup {
+	var inService []string
+	for _, i := range g.Instances {
+		if aws.StringValue(i.LifecycleState) == autoscaling.LifecycleStateInService {
+			inService = append(inService, aws.StringValue(i.InstanceId))
+		}
+	}
+	return AutoScalingGroup{
+		Name:               aws.StringValue(g.AutoScalingGroupName),
+		InstancesInService: len(inService),
+		DesiredCapacity:    int(aws.Int64Value(g.DesiredCapacity)),
+		MinSize:            int(aws.Int64Value(g.MinSize)),
+		MaxSize:            int(aws.Int64Value(g.MaxSize)),
+		InstanceIDs:        inService,
+	}
+}
+
+// Interval for polling auto scaling groups in WaitForGroupHealthy
+var healthCheckInterval = 10 * time.Second
+
+// WaitForGroupHealthy waits until the number of instances in service of the
+// auto scaling group has reached its desired capacity. It returns an error if
+// this doesn't happen within the given timeout.
+func (c *Client) WaitForGroupHealthy(ctx context.Context, name string, timeout time.Duration) error {
+	return c.WaitForInstancesReplaced(ctx, name, nil, timeout)
+}
+
+// WaitForInstancesReplaced is like WaitForGroupHealthy, but also waits until
+// none of the given terminated instances, which the auto scaling group might
+// still list for a while, is in service anymore, i.e. they have been replaced
+// by other instances.
+func (c *Client) WaitForInstancesReplaced(ctx context.Context, name string, terminated []string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		g, err := c.autoScalingGroup(ctx, name)
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("auto scaling group %q did not recover within %s", name, timeout)
+			}
+			return err
+		}
+		inService, remaining := 0, 0
+		for _, id := range g.InstanceIDs {
+			if contains(terminated, id) {
+				remaining++
+			} else {
+				inService++
+			}
+		}
+		if remaining == 0 && inService >= g.DesiredCapacity {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				msg := fmt.Sprintf("auto scaling group %q did not recover within %s (%d of %d instances in service",
+					name, timeout, inService, g.DesiredCapacity)
+				if remaining > 0 {
+					msg += fmt.Sprintf(", %d terminated instance(s) still in service", remaining)
+				}
+				return errors.New(msg + ")")
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
 // DeleteSimpleDBDomain deletes an existing SimpleDB domain.
 func (c *Client) DeleteSimpleDBDomain(domainName string) error {
 	sess, err := c.newSession()
@@ -97,11 +218,40 @@ func (c *Client) DeleteSimpleDBDomain(domainName string) error {
 	return err1
 }
 
+// CallerIdentity describes the AWS identity whose credentials are used.
+type CallerIdentity struct {
+	Account string
+	ARN     string
+	UserID  string
+}
+
+// CallerIdentity returns the identity whose credentials are used to call AWS.
+func (c *Client) CallerIdentity() (*CallerIdentity, error) {
+	sess, err := c.newSession()
+	if err != nil {
+		return nil, err
+	}
+	svc := sts.New(sess)
+
+	out, err := svc.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, err
+	}
+	return &CallerIdentity{
+		Account: aws.StringValue(out.Account),
+		ARN:     aws.StringValue(out.Arn),
+		UserID:  aws.StringValue(out.UserId),
+	}, nil
+}
+
 func (c *Client) newSession() (*session.Session, error) {
 	config := &aws.Config{
 		Region:     aws.String(c.Region),
 		HTTPClient: &http.Client{Timeout: 10 * time.Second},
 	}
+	if c.Endpoint != "" {
+		config.Endpoint = aws.String(c.Endpoint)
+	}
 
 	if role := os.Getenv("AWS_ROLE"); role != "" {
 		if err := assumeRole(role, config); err != nil {
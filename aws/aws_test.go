@@ -0,0 +1,58 @@
+package aws
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+)
+
+func TestWaitForGroupHealthy(t *testing.T) {
+	healthCheckInterval = time.Millisecond
+	as := &fakeAutoScaling{group: "web", desired: 2, instances: map[string]string{
+		"i-1": autoscaling.LifecycleStateInService,
+		"i-2": autoscaling.LifecycleStateInService,
+	}}
+	c := &Client{autoscaling: as}
+
+	// The group still lists the terminated instance as in service, then
+	// launches a replacement
+	polls := 0
+	as.onDescribe = func() {
+		polls++
+		switch polls {
+		case 3:
+			as.instances["i-1"] = autoscaling.LifecycleStateTerminating
+			as.instances["i-3"] = autoscaling.LifecycleStatePending
+		case 5:
+			delete(as.instances, "i-1")
+			as.instances["i-3"] = autoscaling.LifecycleStateInService
+		}
+	}
+	if err := c.WaitForInstancesReplaced(context.Background(), "web", []string{"i-1"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if polls != 5 {
+		t.Errorf("want 5 polls, got %d", polls)
+	}
+
+	// The terminated instance is never replaced
+	as.onDescribe = nil
+	err := c.WaitForInstancesReplaced(context.Background(), "web", []string{"i-3"}, 20*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "did not recover within 20ms (1 of 2 instances in service, 1 terminated instance(s) still in service)") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// Without terminated instances, e.g. after BurnCpu, the group is healthy
+	// as soon as enough instances are in service
+	if err := c.WaitForGroupHealthy(context.Background(), "web", 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	as.instances["i-3"] = autoscaling.LifecycleStatePending
+	err = c.WaitForGroupHealthy(context.Background(), "web", 20*time.Millisecond)
+	if err == nil || !strings.HasSuffix(err.Error(), "(1 of 2 instances in service)") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
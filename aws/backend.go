@@ -0,0 +1,186 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+)
+
+// Client is a chaos backend that injects failures via the AWS API, without
+// Simian Army.
+var _ chaosmonkey.RequestBackend = (*Client)(nil)
+
+// TriggerEventContext implements chaosmonkey.Backend, see
+// TriggerRequestContext.
+func (c *Client) TriggerEventContext(ctx context.Context, group string, strategy chaosmonkey.Strategy) (*chaosmonkey.Event, error) {
+	return c.TriggerRequestContext(ctx, &chaosmonkey.APIRequest{GroupName: group, ChaosType: string(strategy)})
+}
+
+// TriggerRequestContext implements chaosmonkey.RequestBackend by calling
+// InjectFaultFor with the group, strategy, and duration of the request. The
+// region of the request is ignored in favor of the client's. The fault is
+// passed to OnFault, if set, even if it was applied only partially.
+func (c *Client) TriggerRequestContext(ctx context.Context, req *chaosmonkey.APIRequest) (*chaosmonkey.Event, error) {
+	f, err := c.InjectFaultFor(ctx, req.GroupName, chaosmonkey.Strategy(req.ChaosType), req.Duration)
+	if f != nil && c.OnFault != nil {
+		c.OnFault(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f.Event, nil
+}
+
+// InjectFault picks a random instance in service of the auto scaling group and
+// applies the chaos strategy to it. These strategies are supported:
+//
+//   - chaosmonkey.StrategyShutdownInstance terminates the instance. It is also
+//     used if strategy is empty.
+//   - chaosmonkey.StrategyBlockAllNetworkTraffic replaces the security groups
+//     of the instance with the isolation security group of its VPC, see
+//     IsolationGroupName.
+//   - chaosmonkey.StrategyDetachVolumes force-detaches all EBS volumes of the
+//     instance except the root volume.
+//   - All other default strategies run a script on the instance via SSM Run
+//     Command, see NewScript and RunCommand.
+//
+// If the fault was applied only partially, the returned Fault describes the
+// changes made so far along with the error.
+func (c *Client) InjectFault(ctx context.Context, group string, strategy chaosmonkey.Strategy) (*Fault, error) {
+	return c.InjectFaultFor(ctx, group, strategy, 0)
+}
+
+// InjectFaultFor is like InjectFault, but the fault is meant to last only for
+// the given duration, after which the caller should revert it, see
+// Fault.Expires. Scripts run via SSM also revert themselves after the
+// duration, in case the caller fails to. As terminated instances cannot be
+// restored, chaosmonkey.ErrUnsupportedDuration is returned for
+// chaosmonkey.StrategyShutdownInstance.
+func (c *Client) InjectFaultFor(ctx context.Context, group string, strategy chaosmonkey.Strategy, d time.Duration) (*Fault, error) {
+	if d < 0 {
+		return nil, fmt.Errorf("invalid duration %s", d)
+	}
+	params := c.ScriptParams
+	if d > 0 {
+		params.Duration = d
+	}
+	switch strategy {
+	case "", chaosmonkey.StrategyShutdownInstance:
+		strategy = chaosmonkey.StrategyShutdownInstance
+		if d > 0 {
+			return nil, fmt.Errorf("%w: %s cannot be reverted", chaosmonkey.ErrUnsupportedDuration, strategy)
+		}
+	case chaosmonkey.StrategyBlockAllNetworkTraffic,
+		chaosmonkey.StrategyDetachVolumes:
+	default:
+		if !HasScript(strategy) {
+			return nil, fmt.Errorf("%w: %s", chaosmonkey.ErrUnsupportedStrategy, strategy)
+		}
+		if err := params.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	as, err := c.autoScalingAPI()
+	if err != nil {
+		return nil, err
+	}
+	instanceID, err := randomInstance(ctx, as, group)
+	if err != nil {
+		return nil, err
+	}
+
+	svc, err := c.ec2API()
+	if err != nil {
+		return nil, err
+	}
+	f := &Fault{
+		Event: chaosmonkey.Event{
+			InstanceID:           instanceID,
+			AutoScalingGroupName: group,
+			Region:               c.Region,
+			Strategy:             strategy,
+			TriggeredAt:          time.Now().UTC(),
+		},
+		Duration: d,
+	}
+	switch strategy {
+	case chaosmonkey.StrategyShutdownInstance:
+		_, err = svc.TerminateInstancesWithContext(ctx, &ec2.TerminateInstancesInput{
+			InstanceIds: []*string{aws.String(instanceID)},
+		})
+		if err != nil {
+			return nil, err
+		}
+	case chaosmonkey.StrategyBlockAllNetworkTraffic:
+		f.SecurityGroups, err = isolateInstance(ctx, svc, instanceID)
+		if err != nil {
+			return nilIfUnchanged(f), err
+		}
+	case chaosmonkey.StrategyDetachVolumes:
+		f.Volumes, err = detachVolumes(ctx, svc, instanceID)
+		if err != nil {
+			return nilIfUnchanged(f), err
+		}
+	default:
+		if err := c.runScript(ctx, f, params); err != nil {
+			return nilIfUnchanged(f), err
+		}
+	}
+	return f, nil
+}
+
+func (c *Client) ec2API() (ec2iface.EC2API, error) {
+	if c.ec2 != nil {
+		return c.ec2, nil
+	}
+	sess, err := c.newSession()
+	if err != nil {
+		return nil, err
+	}
+	return ec2.New(sess), nil
+}
+
+func (c *Client) autoScalingAPI() (autoscalingiface.AutoScalingAPI, error) {
+	if c.autoscaling != nil {
+		return c.autoscaling, nil
+	}
+	sess, err := c.newSession()
+	if err != nil {
+		return nil, err
+	}
+	return autoscaling.New(sess), nil
+}
+
+// randomInstance returns the ID of a random instance in service of the auto
+// scaling group.
+func randomInstance(ctx context.Context, svc autoscalingiface.AutoScalingAPI, group string) (string, error) {
+	out, err := svc.DescribeAutoScalingGroupsWithContext(ctx, &autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: []*string{aws.String(group)},
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(out.AutoScalingGroups) == 0 {
+		return "", fmt.Errorf("%w: %s", chaosmonkey.ErrGroupNotFound, group)
+	}
+
+	var instances []string
+	for _, i := range out.AutoScalingGroups[0].Instances {
+		if aws.StringValue(i.LifecycleState) == autoscaling.LifecycleStateInService {
+			instances = append(instances, aws.StringValue(i.InstanceId))
+		}
+	}
+	if len(instances) == 0 {
+		return "", fmt.Errorf("%w: %s", chaosmonkey.ErrNoInstance, group)
+	}
+	return instances[rand.Intn(len(instances))], nil
+}
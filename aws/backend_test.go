@@ -0,0 +1,375 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/google/go-cmp/cmp"
+
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+)
+
+// fakeAutoScaling is a stand-in for the auto scaling API knowing a single
+// group.
+type fakeAutoScaling struct {
+	autoscalingiface.AutoScalingAPI
+	group     string
+	desired   int64
+	instances map[string]string // ID -> lifecycle state
+
+	// Optional function called before each lookup, e.g. to change states
+	onDescribe func()
+}
+
+func (f *fakeAutoScaling) DescribeAutoScalingGroupsWithContext(ctx aws.Context, in *autoscaling.DescribeAutoScalingGroupsInput, opts ...request.Option) (*autoscaling.DescribeAutoScalingGroupsOutput, error) {
+	if f.onDescribe != nil {
+		f.onDescribe()
+	}
+	out := &autoscaling.DescribeAutoScalingGroupsOutput{}
+	if aws.StringValue(in.AutoScalingGroupNames[0]) != f.group {
+		return out, nil
+	}
+	g := &autoscaling.Group{
+		AutoScalingGroupName: aws.String(f.group),
+		DesiredCapacity:      aws.Int64(f.desired),
+	}
+	for id, state := range f.instances {
+		g.Instances = append(g.Instances, &autoscaling.Instance{
+			InstanceId:     aws.String(id),
+			LifecycleState: aws.String(state),
+		})
+	}
+	out.AutoScalingGroups = append(out.AutoScalingGroups, g)
+	return out, nil
+}
+
+// fakeEC2 is a stand-in for the EC2 API keeping instances, security groups,
+// and volume attachments in memory.
+type fakeEC2 struct {
+	ec2iface.EC2API
+	instances  map[string]*ec2.Instance
+	groups     map[string]*ec2.SecurityGroup
+	terminated []string
+	calls      []string
+}
+
+func newFakeEC2(instances ...*ec2.Instance) *fakeEC2 {
+	f := &fakeEC2{
+		instances: make(map[string]*ec2.Instance),
+		groups:    make(map[string]*ec2.SecurityGroup),
+	}
+	for _, i := range instances {
+		f.instances[aws.StringValue(i.InstanceId)] = i
+	}
+	return f
+}
+
+func (f *fakeEC2) TerminateInstancesWithContext(ctx aws.Context, in *ec2.TerminateInstancesInput, opts ...request.Option) (*ec2.TerminateInstancesOutput, error) {
+	f.terminated = append(f.terminated, aws.StringValueSlice(in.InstanceIds)...)
+	return &ec2.TerminateInstancesOutput{}, nil
+}
+
+func (f *fakeEC2) DescribeInstancesWithContext(ctx aws.Context, in *ec2.DescribeInstancesInput, opts ...request.Option) (*ec2.DescribeInstancesOutput, error) {
+	out := &ec2.DescribeInstancesOutput{}
+	for _, id := range in.InstanceIds {
+		if i, ok := f.instances[aws.StringValue(id)]; ok {
+			out.Reservations = append(out.Reservations, &ec2.Reservation{Instances: []*ec2.Instance{i}})
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeEC2) DescribeSecurityGroupsWithContext(ctx aws.Context, in *ec2.DescribeSecurityGroupsInput, opts ...request.Option) (*ec2.DescribeSecurityGroupsOutput, error) {
+	out := &ec2.DescribeSecurityGroupsOutput{}
+	for _, id := range in.GroupIds {
+		if g, ok := f.groups[aws.StringValue(id)]; ok {
+			out.SecurityGroups = append(out.SecurityGroups, g)
+		}
+	}
+	for _, g := range f.groups {
+		match := len(in.Filters) > 0
+		for _, filter := range in.Filters {
+			value := map[string]*string{"vpc-id": g.VpcId, "group-name": g.GroupName}[aws.StringValue(filter.Name)]
+			if aws.StringValue(value) != aws.StringValue(filter.Values[0]) {
+				match = false
+			}
+		}
+		if match {
+			out.SecurityGroups = append(out.SecurityGroups, g)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeEC2) CreateSecurityGroupWithContext(ctx aws.Context, in *ec2.CreateSecurityGroupInput, opts ...request.Option) (*ec2.CreateSecurityGroupOutput, error) {
+	id := fmt.Sprintf("sg-%d", len(f.groups)+1)
+	f.groups[id] = &ec2.SecurityGroup{
+		GroupId:   aws.String(id),
+		GroupName: in.GroupName,
+		VpcId:     in.VpcId,
+		IpPermissionsEgress: []*ec2.IpPermission{{
+			IpProtocol: aws.String("-1"),
+			IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+		}},
+	}
+	f.calls = append(f.calls, "CreateSecurityGroup")
+	return &ec2.CreateSecurityGroupOutput{GroupId: aws.String(id)}, nil
+}
+
+func (f *fakeEC2) RevokeSecurityGroupEgressWithContext(ctx aws.Context, in *ec2.RevokeSecurityGroupEgressInput, opts ...request.Option) (*ec2.RevokeSecurityGroupEgressOutput, error) {
+	f.groups[aws.StringValue(in.GroupId)].IpPermissionsEgress = nil
+	f.calls = append(f.calls, "RevokeSecurityGroupEgress")
+	return &ec2.RevokeSecurityGroupEgressOutput{}, nil
+}
+
+func (f *fakeEC2) ModifyNetworkInterfaceAttributeWithContext(ctx aws.Context, in *ec2.ModifyNetworkInterfaceAttributeInput, opts ...request.Option) (*ec2.ModifyNetworkInterfaceAttributeOutput, error) {
+	for _, i := range f.instances {
+		for _, ni := range i.NetworkInterfaces {
+			if aws.StringValue(ni.NetworkInterfaceId) != aws.StringValue(in.NetworkInterfaceId) {
+				continue
+			}
+			ni.Groups = nil
+			for _, id := range in.Groups {
+				ni.Groups = append(ni.Groups, &ec2.GroupIdentifier{GroupId: id})
+			}
+			return &ec2.ModifyNetworkInterfaceAttributeOutput{}, nil
+		}
+	}
+	return nil, fmt.Errorf("network interface %s does not exist", aws.StringValue(in.NetworkInterfaceId))
+}
+
+func (f *fakeEC2) DetachVolumeWithContext(ctx aws.Context, in *ec2.DetachVolumeInput, opts ...request.Option) (*ec2.VolumeAttachment, error) {
+	if !aws.BoolValue(in.Force) {
+		return nil, errors.New("volume must be force-detached")
+	}
+	i := f.instances[aws.StringValue(in.InstanceId)]
+	for n, m := range i.BlockDeviceMappings {
+		if aws.StringValue(m.Ebs.VolumeId) == aws.StringValue(in.VolumeId) {
+			i.BlockDeviceMappings = append(i.BlockDeviceMappings[:n], i.BlockDeviceMappings[n+1:]...)
+			return &ec2.VolumeAttachment{}, nil
+		}
+	}
+	return nil, fmt.Errorf("volume %s is not attached", aws.StringValue(in.VolumeId))
+}
+
+func (f *fakeEC2) AttachVolumeWithContext(ctx aws.Context, in *ec2.AttachVolumeInput, opts ...request.Option) (*ec2.VolumeAttachment, error) {
+	i := f.instances[aws.StringValue(in.InstanceId)]
+	i.BlockDeviceMappings = append(i.BlockDeviceMappings, &ec2.InstanceBlockDeviceMapping{
+		DeviceName: in.Device,
+		Ebs:        &ec2.EbsInstanceBlockDevice{VolumeId: in.VolumeId, DeleteOnTermination: aws.Bool(false)},
+	})
+	return &ec2.VolumeAttachment{}, nil
+}
+
+func (f *fakeEC2) WaitUntilVolumeAvailableWithContext(ctx aws.Context, in *ec2.DescribeVolumesInput, opts ...request.WaiterOption) error {
+	return nil
+}
+
+func (f *fakeEC2) WaitUntilVolumeInUseWithContext(ctx aws.Context, in *ec2.DescribeVolumesInput, opts ...request.WaiterOption) error {
+	return nil
+}
+
+func (f *fakeEC2) ModifyInstanceAttributeWithContext(ctx aws.Context, in *ec2.ModifyInstanceAttributeInput, opts ...request.Option) (*ec2.ModifyInstanceAttributeOutput, error) {
+	i := f.instances[aws.StringValue(in.InstanceId)]
+	for _, spec := range in.BlockDeviceMappings {
+		for _, m := range i.BlockDeviceMappings {
+			if aws.StringValue(m.DeviceName) == aws.StringValue(spec.DeviceName) {
+				m.Ebs.DeleteOnTermination = spec.Ebs.DeleteOnTermination
+			}
+		}
+	}
+	return &ec2.ModifyInstanceAttributeOutput{}, nil
+}
+
+func newTestClient(f *fakeEC2) *Client {
+	as := &fakeAutoScaling{group: "web", instances: make(map[string]string)}
+	for id := range f.instances {
+		as.instances[id] = autoscaling.LifecycleStateInService
+	}
+	return &Client{Region: "eu-west-1", ec2: f, autoscaling: as}
+}
+
+func TestShutdownInstance(t *testing.T) {
+	f := newFakeEC2(&ec2.Instance{InstanceId: aws.String("i-1")})
+	c := newTestClient(f)
+	c.autoscaling.(*fakeAutoScaling).instances["i-2"] = "Terminating"
+
+	event, err := c.TriggerEventContext(context.Background(), "web", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.InstanceID != "i-1" || event.Strategy != chaosmonkey.StrategyShutdownInstance || event.Region != "eu-west-1" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if diff := cmp.Diff([]string{"i-1"}, f.terminated); diff != "" {
+		t.Fatal(diff)
+	}
+
+	if _, err := c.TriggerEventContext(context.Background(), "db", ""); !errors.Is(err, chaosmonkey.ErrGroupNotFound) {
+		t.Fatalf("expected ErrGroupNotFound, got %v", err)
+	}
+	if _, err := c.TriggerEventContext(context.Background(), "web", "KillNginx"); !errors.Is(err, chaosmonkey.ErrUnsupportedStrategy) {
+		t.Fatalf("expected ErrUnsupportedStrategy, got %v", err)
+	}
+
+	c.autoscaling.(*fakeAutoScaling).instances["i-1"] = "Terminating"
+	if _, err := c.TriggerEventContext(context.Background(), "web", ""); !errors.Is(err, chaosmonkey.ErrNoInstance) {
+		t.Fatalf("expected ErrNoInstance, got %v", err)
+	}
+}
+
+func securityGroups(i *ec2.Instance) map[string][]string {
+	groups := make(map[string][]string)
+	for _, ni := range i.NetworkInterfaces {
+		for _, g := range ni.Groups {
+			groups[aws.StringValue(ni.NetworkInterfaceId)] = append(groups[aws.StringValue(ni.NetworkInterfaceId)], aws.StringValue(g.GroupId))
+		}
+	}
+	return groups
+}
+
+func TestBlockAllNetworkTraffic(t *testing.T) {
+	instance := &ec2.Instance{
+		InstanceId: aws.String("i-1"),
+		VpcId:      aws.String("vpc-1"),
+		NetworkInterfaces: []*ec2.InstanceNetworkInterface{
+			{
+				NetworkInterfaceId: aws.String("eni-1"),
+				Groups:             []*ec2.GroupIdentifier{{GroupId: aws.String("sg-web")}, {GroupId: aws.String("sg-ssh")}},
+			},
+			{
+				NetworkInterfaceId: aws.String("eni-2"),
+				Groups:             []*ec2.GroupIdentifier{{GroupId: aws.String("sg-db")}},
+			},
+		},
+	}
+	f := newFakeEC2(instance)
+	c := newTestClient(f)
+	var faults []*Fault
+	c.OnFault = func(fault *Fault) { faults = append(faults, fault) }
+	original := securityGroups(instance)
+
+	for n := 0; n < 2; n++ {
+		event, err := c.TriggerEventContext(context.Background(), "web", chaosmonkey.StrategyBlockAllNetworkTraffic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Strategy != chaosmonkey.StrategyBlockAllNetworkTraffic {
+			t.Fatalf("unexpected event %+v", event)
+		}
+		if diff := cmp.Diff(map[string][]string{"eni-1": {"sg-1"}, "eni-2": {"sg-1"}}, securityGroups(instance)); diff != "" {
+			t.Fatal(diff)
+		}
+		// Undo isolation before the next round
+		if err := c.RevertFault(context.Background(), faults[n]); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(original, securityGroups(instance)); diff != "" {
+			t.Fatal(diff)
+		}
+	}
+
+	// The isolation group is created once and stripped of its outbound rules
+	if diff := cmp.Diff([]string{"CreateSecurityGroup", "RevokeSecurityGroupEgress"}, f.calls); diff != "" {
+		t.Fatal(diff)
+	}
+	if g := f.groups["sg-1"]; aws.StringValue(g.GroupName) != IsolationGroupName || aws.StringValue(g.VpcId) != "vpc-1" {
+		t.Fatalf("unexpected isolation group %+v", g)
+	}
+	if diff := cmp.Diff(original, faults[0].SecurityGroups); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestDetachVolumes(t *testing.T) {
+	instance := &ec2.Instance{
+		InstanceId:     aws.String("i-1"),
+		RootDeviceName: aws.String("/dev/xvda"),
+		BlockDeviceMappings: []*ec2.InstanceBlockDeviceMapping{
+			{DeviceName: aws.String("/dev/xvda"), Ebs: &ec2.EbsInstanceBlockDevice{VolumeId: aws.String("vol-root"), DeleteOnTermination: aws.Bool(true)}},
+			{DeviceName: aws.String("/dev/xvdb"), Ebs: &ec2.EbsInstanceBlockDevice{VolumeId: aws.String("vol-data"), DeleteOnTermination: aws.Bool(true)}},
+			{DeviceName: aws.String("/dev/xvdc"), Ebs: &ec2.EbsInstanceBlockDevice{VolumeId: aws.String("vol-logs"), DeleteOnTermination: aws.Bool(false)}},
+		},
+	}
+	f := newFakeEC2(instance)
+	c := newTestClient(f)
+
+	fault, err := c.InjectFault(context.Background(), "web", chaosmonkey.StrategyDetachVolumes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []VolumeAttachment{
+		{VolumeID: "vol-data", Device: "/dev/xvdb", DeleteOnTermination: true},
+		{VolumeID: "vol-logs", Device: "/dev/xvdc"},
+	}
+	if diff := cmp.Diff(want, fault.Volumes); diff != "" {
+		t.Fatal(diff)
+	}
+	if len(instance.BlockDeviceMappings) != 1 || aws.StringValue(instance.BlockDeviceMappings[0].Ebs.VolumeId) != "vol-root" {
+		t.Fatalf("root volume must be the only volume left, got %+v", instance.BlockDeviceMappings)
+	}
+
+	// Nothing left to detach
+	if _, err := c.InjectFault(context.Background(), "web", chaosmonkey.StrategyDetachVolumes); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if err := c.RevertFault(context.Background(), fault); err != nil {
+		t.Fatal(err)
+	}
+	var got []VolumeAttachment
+	for _, m := range instance.BlockDeviceMappings[1:] {
+		got = append(got, VolumeAttachment{
+			VolumeID:            aws.StringValue(m.Ebs.VolumeId),
+			Device:              aws.StringValue(m.DeviceName),
+			DeleteOnTermination: aws.BoolValue(m.Ebs.DeleteOnTermination),
+		})
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].Device < got[j].Device })
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestRevertShutdownInstance(t *testing.T) {
+	f := newFakeEC2(&ec2.Instance{InstanceId: aws.String("i-1")})
+	c := newTestClient(f)
+
+	fault, err := c.InjectFault(context.Background(), "web", chaosmonkey.StrategyShutdownInstance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fault.Reversible() {
+		t.Fatal("terminated instance must not be reversible")
+	}
+	if err := c.RevertFault(context.Background(), fault); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestRevertTerminatedInstance(t *testing.T) {
+	f := newFakeEC2(&ec2.Instance{
+		InstanceId: aws.String("i-1"),
+		State:      &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameTerminated)},
+	})
+	c := newTestClient(f)
+
+	for _, id := range []string{"i-1", "i-2"} {
+		fault := &Fault{
+			Event:          chaosmonkey.Event{InstanceID: id, Strategy: chaosmonkey.StrategyBlockAllNetworkTraffic},
+			SecurityGroups: map[string][]string{"eni-1": {"sg-web"}},
+		}
+		if err := c.RevertFault(context.Background(), fault); !errors.Is(err, ErrInstanceGone) {
+			t.Errorf("%s: expected ErrInstanceGone, got %v", id, err)
+		}
+	}
+}
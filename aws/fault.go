@@ -0,0 +1,309 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+)
+
+// IsolationGroupName is the name of the security group used to block all
+// network traffic of instances. It is created in the VPC of an instance if it
+// does not exist yet, and all its rules are removed before use.
+const IsolationGroupName = "chaosmonkey-isolation"
+
+// ErrInstanceGone is returned by RevertFault if the instance affected by the
+// fault has been terminated meanwhile, e.g. replaced by its auto scaling group,
+// so there is nothing left to revert.
+var ErrInstanceGone = errors.New("instance does not exist anymore")
+
+// Fault describes a failure injected into an instance by InjectFault, along
+// with the original state of the instance needed to revert it.
+type Fault struct {
+	chaosmonkey.Event
+
+	// Security groups of the instance's network interfaces, by interface
+	// ID, before they were replaced by the isolation security group
+	SecurityGroups map[string][]string `json:",omitempty"`
+
+	// EBS volumes detached from the instance
+	Volumes []VolumeAttachment `json:",omitempty"`
+
+	// ID of the SSM command that ran the script of the strategy, and the
+	// script reverting it
+	CommandID    string `json:",omitempty"`
+	RevertScript string `json:",omitempty"`
+
+	// Time after which the fault is to be reverted, see InjectFaultFor
+	Duration time.Duration `json:",omitempty"`
+}
+
+// Expires returns the time after which the fault is to be reverted, or the
+// zero time if it has no duration.
+func (f *Fault) Expires() time.Time {
+	if f.Duration <= 0 {
+		return time.Time{}
+	}
+	return f.TriggeredAt.Add(f.Duration)
+}
+
+// VolumeAttachment describes how an EBS volume was attached to an instance.
+type VolumeAttachment struct {
+	VolumeID            string
+	Device              string
+	DeleteOnTermination bool `json:",omitempty"`
+}
+
+// Reversible reports whether the fault can be reverted by RevertFault.
+func (f *Fault) Reversible() bool {
+	return len(f.SecurityGroups) > 0 || len(f.Volumes) > 0 || f.RevertScript != ""
+}
+
+// nilIfUnchanged returns nil if the fault did not change the instance.
+func nilIfUnchanged(f *Fault) *Fault {
+	if !f.Reversible() {
+		return nil
+	}
+	return f
+}
+
+// RevertFault restores the original state of the instance affected by the
+// fault, i.e. its security groups or detached EBS volumes, or runs the script
+// reverting the strategy via SSM. Terminated instances cannot be restored, in
+// which case ErrInstanceGone is returned.
+func (c *Client) RevertFault(ctx context.Context, f *Fault) error {
+	if !f.Reversible() {
+		return fmt.Errorf("chaos strategy %s cannot be reverted", f.Strategy)
+	}
+	svc, err := c.ec2API()
+	if err != nil {
+		return err
+	}
+	instance, err := describeInstance(ctx, svc, f.InstanceID)
+	if err != nil {
+		return err
+	}
+	if state := instance.State; state != nil {
+		switch aws.StringValue(state.Name) {
+		case ec2.InstanceStateNameShuttingDown, ec2.InstanceStateNameTerminated:
+			return fmt.Errorf("%w: %s", ErrInstanceGone, f.InstanceID)
+		}
+	}
+
+	var interfaces []string
+	for id := range f.SecurityGroups {
+		interfaces = append(interfaces, id)
+	}
+	sort.Strings(interfaces)
+	for _, id := range interfaces {
+		_, err := svc.ModifyNetworkInterfaceAttributeWithContext(ctx, &ec2.ModifyNetworkInterfaceAttributeInput{
+			NetworkInterfaceId: aws.String(id),
+			Groups:             aws.StringSlice(f.SecurityGroups[id]),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to restore security groups of %s: %s", id, err)
+		}
+	}
+
+	for _, v := range f.Volumes {
+		if err := attachVolume(ctx, svc, f.InstanceID, v); err != nil {
+			return fmt.Errorf("failed to reattach volume %s: %s", v.VolumeID, err)
+		}
+	}
+
+	if f.RevertScript != "" {
+		_, err := c.RunCommand(ctx, f.InstanceID, f.RevertScript, "chaosmonkey revert "+string(f.Strategy))
+		if err != nil {
+			return fmt.Errorf("failed to revert %s: %s", f.Strategy, err)
+		}
+	}
+	return nil
+}
+
+// describeInstance returns the instance with the given ID, or ErrInstanceGone
+// if it does not exist.
+func describeInstance(ctx context.Context, svc ec2iface.EC2API, id string) (*ec2.Instance, error) {
+	out, err := svc.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: []*string{aws.String(id)},
+	})
+	if e, ok := err.(awserr.Error); ok && e.Code() == "InvalidInstanceID.NotFound" {
+		return nil, fmt.Errorf("%w: %s", ErrInstanceGone, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range out.Reservations {
+		if len(r.Instances) > 0 {
+			return r.Instances[0], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrInstanceGone, id)
+}
+
+// isolateInstance replaces the security groups of all network interfaces of
+// the instance with the isolation security group. It returns the original
+// security groups of the interfaces changed.
+func isolateInstance(ctx context.Context, svc ec2iface.EC2API, id string) (map[string][]string, error) {
+	instance, err := describeInstance(ctx, svc, id)
+	if err != nil {
+		return nil, err
+	}
+	vpc := aws.StringValue(instance.VpcId)
+	if vpc == "" {
+		return nil, fmt.Errorf("instance %s is not in a VPC", id)
+	}
+	if len(instance.NetworkInterfaces) == 0 {
+		return nil, fmt.Errorf("instance %s has no network interfaces", id)
+	}
+	isolation, err := isolationGroup(ctx, svc, vpc)
+	if err != nil {
+		return nil, err
+	}
+
+	original := make(map[string][]string)
+	for _, ni := range instance.NetworkInterfaces {
+		var groups []string
+		for _, g := range ni.Groups {
+			groups = append(groups, aws.StringValue(g.GroupId))
+		}
+		_, err := svc.ModifyNetworkInterfaceAttributeWithContext(ctx, &ec2.ModifyNetworkInterfaceAttributeInput{
+			NetworkInterfaceId: ni.NetworkInterfaceId,
+			Groups:             []*string{aws.String(isolation)},
+		})
+		if err != nil {
+			return original, err
+		}
+		original[aws.StringValue(ni.NetworkInterfaceId)] = groups
+	}
+	return original, nil
+}
+
+// isolationGroup returns the ID of the isolation security group of the VPC,
+// creating it if necessary, and makes sure it has no rules.
+func isolationGroup(ctx context.Context, svc ec2iface.EC2API, vpc string) (string, error) {
+	out, err := svc.DescribeSecurityGroupsWithContext(ctx, &ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("vpc-id"), Values: []*string{aws.String(vpc)}},
+			{Name: aws.String("group-name"), Values: []*string{aws.String(IsolationGroupName)}},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(out.SecurityGroups) == 0 {
+		created, err := svc.CreateSecurityGroupWithContext(ctx, &ec2.CreateSecurityGroupInput{
+			GroupName:   aws.String(IsolationGroupName),
+			Description: aws.String("Blocks all network traffic of instances isolated by Chaos Monkey"),
+			VpcId:       aws.String(vpc),
+		})
+		if err != nil {
+			return "", err
+		}
+		// Look up the group again to get its default outbound rules
+		out, err = svc.DescribeSecurityGroupsWithContext(ctx, &ec2.DescribeSecurityGroupsInput{
+			GroupIds: []*string{created.GroupId},
+		})
+		if err != nil {
+			return "", err
+		}
+		if len(out.SecurityGroups) == 0 {
+			return "", fmt.Errorf("security group %s does not exist", aws.StringValue(created.GroupId))
+		}
+	}
+
+	g := out.SecurityGroups[0]
+	if len(g.IpPermissions) > 0 {
+		_, err := svc.RevokeSecurityGroupIngressWithContext(ctx, &ec2.RevokeSecurityGroupIngressInput{
+			GroupId:       g.GroupId,
+			IpPermissions: g.IpPermissions,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(g.IpPermissionsEgress) > 0 {
+		_, err := svc.RevokeSecurityGroupEgressWithContext(ctx, &ec2.RevokeSecurityGroupEgressInput{
+			GroupId:       g.GroupId,
+			IpPermissions: g.IpPermissionsEgress,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return aws.StringValue(g.GroupId), nil
+}
+
+// detachVolumes force-detaches all EBS volumes of the instance except the root
+// volume. It returns the volumes detached.
+func detachVolumes(ctx context.Context, svc ec2iface.EC2API, id string) ([]VolumeAttachment, error) {
+	instance, err := describeInstance(ctx, svc, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var detached []VolumeAttachment
+	for _, m := range instance.BlockDeviceMappings {
+		if m.Ebs == nil || aws.StringValue(m.DeviceName) == aws.StringValue(instance.RootDeviceName) {
+			continue
+		}
+		_, err := svc.DetachVolumeWithContext(ctx, &ec2.DetachVolumeInput{
+			VolumeId:   m.Ebs.VolumeId,
+			InstanceId: aws.String(id),
+			Device:     m.DeviceName,
+			Force:      aws.Bool(true),
+		})
+		if err != nil {
+			return detached, err
+		}
+		detached = append(detached, VolumeAttachment{
+			VolumeID:            aws.StringValue(m.Ebs.VolumeId),
+			Device:              aws.StringValue(m.DeviceName),
+			DeleteOnTermination: aws.BoolValue(m.Ebs.DeleteOnTermination),
+		})
+	}
+	if len(detached) == 0 {
+		return nil, fmt.Errorf("instance %s has no EBS volumes other than the root volume", id)
+	}
+	return detached, nil
+}
+
+// attachVolume attaches the volume to the instance again once it is available,
+// restoring its DeleteOnTermination flag.
+func attachVolume(ctx context.Context, svc ec2iface.EC2API, id string, v VolumeAttachment) error {
+	volumes := &ec2.DescribeVolumesInput{VolumeIds: []*string{aws.String(v.VolumeID)}}
+	if err := svc.WaitUntilVolumeAvailableWithContext(ctx, volumes); err != nil {
+		return err
+	}
+	_, err := svc.AttachVolumeWithContext(ctx, &ec2.AttachVolumeInput{
+		VolumeId:   aws.String(v.VolumeID),
+		InstanceId: aws.String(id),
+		Device:     aws.String(v.Device),
+	})
+	if err != nil {
+		return err
+	}
+	if !v.DeleteOnTermination {
+		return nil
+	}
+	if err := svc.WaitUntilVolumeInUseWithContext(ctx, volumes); err != nil {
+		return err
+	}
+	_, err = svc.ModifyInstanceAttributeWithContext(ctx, &ec2.ModifyInstanceAttributeInput{
+		InstanceId: aws.String(id),
+		BlockDeviceMappings: []*ec2.InstanceBlockDeviceMappingSpecification{{
+			DeviceName: aws.String(v.Device),
+			Ebs: &ec2.EbsInstanceBlockDeviceSpecification{
+				VolumeId:            aws.String(v.VolumeID),
+				DeleteOnTermination: aws.Bool(true),
+			},
+		}},
+	})
+	return err
+}
@@ -0,0 +1 @@
+pkill -f chaosmonkey-burncpu || true
@@ -0,0 +1,6 @@
+set -e
+# Run busy loops on {{.Intensity}}% of the CPUs
+workers=$(( ($(nproc) * {{.Intensity}} + 99) / 100 ))
+for i in $(seq "$workers"); do
+	nohup sh -c 'while :; do :; done' chaosmonkey-burncpu >/dev/null 2>&1 &
+done
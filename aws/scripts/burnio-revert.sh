@@ -0,0 +1,2 @@
+pkill -f chaosmonkey-burnio || true
+rm -f /var/tmp/chaosmonkey-burnio-*
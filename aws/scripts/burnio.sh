@@ -0,0 +1,7 @@
+set -e
+# Write to disk with up to 4 processes, depending on intensity
+workers=$(( ({{.Intensity}} + 24) / 25 ))
+for i in $(seq "$workers"); do
+	nohup sh -c 'while :; do dd if=/dev/zero of="$1" bs=1M count=1024 oflag=direct; done' chaosmonkey-burnio \
+		"/var/tmp/chaosmonkey-burnio-$i" >/dev/null 2>&1 &
+done
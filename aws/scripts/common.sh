@@ -0,0 +1,33 @@
+{{- /* Snippets shared by the scripts of chaos strategies */ -}}
+
+{{define "region" -}}
+region={{.Region}}
+if [ -z "$region" ]; then
+	token=$(curl -sf -X PUT -H "X-aws-ec2-metadata-token-ttl-seconds: 60" http://169.254.169.254/latest/api/token)
+	region=$(curl -sf -H "X-aws-ec2-metadata-token: $token" http://169.254.169.254/latest/meta-data/placement/region)
+fi
+{{- end}}
+
+{{define "interface" -}}
+iface=$(ip route show default | awk '{print $5; exit}')
+{{- end}}
+
+{{define "netem" -}}
+{{template "interface"}}
+{{if .TargetCIDR -}}
+tc qdisc add dev "$iface" root handle 1: prio
+tc qdisc add dev "$iface" parent 1:3 handle 30: netem {{.Netem}}
+tc filter add dev "$iface" protocol ip parent 1:0 prio 3 u32 match ip dst {{.TargetCIDR}} flowid 1:3
+{{- else -}}
+tc qdisc add dev "$iface" root netem {{.Netem}}
+{{- end}}
+{{- end}}
+
+{{define "netem-revert" -}}
+{{template "interface"}}
+tc qdisc del dev "$iface" root 2>/dev/null || true
+{{- end}}
+
+{{define "hosts-revert" -}}
+sed -i '/# chaosmonkey-{{.Name}}$/d' /etc/hosts
+{{- end}}
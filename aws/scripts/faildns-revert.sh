@@ -0,0 +1,3 @@
+for proto in udp tcp; do
+	while iptables -D OUTPUT -p "$proto" --dport 53 -m comment --comment chaosmonkey-faildns -j DROP 2>/dev/null; do :; done
+done
@@ -0,0 +1,3 @@
+set -e
+iptables -I OUTPUT -p udp --dport 53 -m comment --comment chaosmonkey-faildns -j DROP
+iptables -I OUTPUT -p tcp --dport 53 -m comment --comment chaosmonkey-faildns -j DROP
@@ -0,0 +1,3 @@
+set -e
+{{template "region" .}}
+echo "127.0.0.1 dynamodb.$region.amazonaws.com # chaosmonkey-{{.Name}}" >> /etc/hosts
@@ -0,0 +1 @@
+{{template "hosts-revert" .}}
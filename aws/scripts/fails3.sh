@@ -0,0 +1,5 @@
+set -e
+{{template "region" .}}
+for host in s3.amazonaws.com s3.$region.amazonaws.com s3-$region.amazonaws.com s3-external-1.amazonaws.com; do
+	echo "127.0.0.1 $host # chaosmonkey-{{.Name}}" >> /etc/hosts
+done
@@ -0,0 +1 @@
+rm -f /chaosmonkey-filldisk
@@ -0,0 +1,5 @@
+set -e
+# Fill {{.Intensity}}% of the free space of the root device
+size=$(( $(df -Pm / | awk 'NR == 2 {print $4}') * {{.Intensity}} / 100 ))
+fallocate -l "${size}M" /chaosmonkey-filldisk 2>/dev/null ||
+	dd if=/dev/zero of=/chaosmonkey-filldisk bs=1M count="$size" 2>/dev/null || true
@@ -0,0 +1,2 @@
+pkill -f chaosmonkey-killprocesses.sh || true
+rm -f /var/tmp/chaosmonkey-killprocesses.sh
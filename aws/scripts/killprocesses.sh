@@ -0,0 +1,10 @@
+set -e
+# The loop runs from a file, so that it does not match the processes it kills
+cat > /var/tmp/chaosmonkey-killprocesses.sh <<'SCRIPT'
+while :; do
+	pkill -KILL -f java
+	pkill -KILL -f python
+	sleep 1
+done
+SCRIPT
+nohup sh /var/tmp/chaosmonkey-killprocesses.sh >/dev/null 2>&1 &
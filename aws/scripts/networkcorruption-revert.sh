@@ -0,0 +1 @@
+{{template "netem-revert" .}}
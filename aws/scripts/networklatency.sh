@@ -0,0 +1,2 @@
+set -e
+{{template "netem" .}}
@@ -0,0 +1 @@
+ip route del blackhole {{.TargetCIDR}} 2>/dev/null || true
@@ -0,0 +1,2 @@
+set -e
+ip route add blackhole {{.TargetCIDR}}
@@ -0,0 +1,253 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"net"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+)
+
+// ScriptParams parameterize the scripts run on instances for chaos strategies
+// that would require SSH access with Simian Army.
+type ScriptParams struct {
+	// Time after which the fault is reverted by the instance itself (never
+	// by default)
+	Duration time.Duration
+
+	// Intensity of the fault in percent, whose meaning depends on the
+	// strategy: share of CPUs to burn (BurnCpu), disk load (BurnIo), share
+	// of free disk space to fill (FillDisk), or share of network packets
+	// to corrupt (NetworkCorruption) or drop (NetworkLoss). Defaults to
+	// 100%, except for NetworkCorruption (5%) and NetworkLoss (7%).
+	Intensity int
+
+	// Delay added to network packets by NetworkLatency, varying by 50% (1
+	// second by default)
+	Latency time.Duration
+
+	// Network whose traffic is affected by NetworkCorruption,
+	// NetworkLatency, and NetworkLoss (all traffic by default), or which
+	// is null-routed by NullRoute (10.0.0.0/8 by default)
+	TargetCIDR string
+}
+
+// Validate checks the parameters for invalid values.
+func (p *ScriptParams) Validate() error {
+	if p.Duration < 0 {
+		return fmt.Errorf("invalid duration %s", p.Duration)
+	}
+	if p.Intensity < 0 || p.Intensity > 100 {
+		return fmt.Errorf("intensity must be between 0 and 100, got %d", p.Intensity)
+	}
+	if p.Latency < 0 {
+		return fmt.Errorf("invalid latency %s", p.Latency)
+	}
+	if p.TargetCIDR != "" {
+		if _, _, err := net.ParseCIDR(p.TargetCIDR); err != nil {
+			return fmt.Errorf("invalid target CIDR: %s", err)
+		}
+	}
+	return nil
+}
+
+// Default intensity of strategies other than 100%
+var defaultIntensity = map[chaosmonkey.Strategy]int{
+	chaosmonkey.StrategyNetworkCorruption: 5,
+	chaosmonkey.StrategyNetworkLoss:       7,
+}
+
+//go:embed scripts/*.sh
+var scriptFiles embed.FS
+
+var scripts = template.Must(template.ParseFS(scriptFiles, "scripts/*.sh"))
+
+// Script is a shell script applying a chaos strategy to an instance, along
+// with the script reverting it.
+type Script struct {
+	Strategy chaosmonkey.Strategy
+	Apply    string
+	Revert   string
+}
+
+// HasScript reports whether there is a script for the chaos strategy.
+func HasScript(strategy chaosmonkey.Strategy) bool {
+	return scripts.Lookup(scriptName(strategy)) != nil
+}
+
+func scriptName(strategy chaosmonkey.Strategy) string {
+	return strings.ToLower(string(strategy)) + ".sh"
+}
+
+// scriptData is passed to script templates.
+type scriptData struct {
+	ScriptParams
+
+	// Lowercase name of the strategy
+	Name string
+
+	// AWS region of the instance, looked up from instance metadata if empty
+	Region string
+}
+
+// Netem returns the options of the network emulator of tc(8) used by the
+// network strategies.
+func (d *scriptData) Netem() string {
+	switch d.Name {
+	case "networkcorruption":
+		return fmt.Sprintf("corrupt %d%%", d.Intensity)
+	case "networklatency":
+		ms := d.Latency.Milliseconds()
+		return fmt.Sprintf("delay %dms %dms", ms, ms/2)
+	}
+	return fmt.Sprintf("loss %d%%", d.Intensity)
+}
+
+// NewScript returns the script for the chaos strategy. If params.Duration is
+// set, the script schedules its own reversal.
+func NewScript(strategy chaosmonkey.Strategy, region string, params ScriptParams) (*Script, error) {
+	if !HasScript(strategy) {
+		return nil, fmt.Errorf("%w: %s", chaosmonkey.ErrUnsupportedStrategy, strategy)
+	}
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	if params.Intensity == 0 {
+		params.Intensity = 100
+		if i, ok := defaultIntensity[strategy]; ok {
+			params.Intensity = i
+		}
+	}
+	if params.Latency == 0 {
+		params.Latency = time.Second
+	}
+	if params.TargetCIDR == "" && strategy == chaosmonkey.StrategyNullRoute {
+		params.TargetCIDR = "10.0.0.0/8"
+	}
+	data := &scriptData{
+		ScriptParams: params,
+		Name:         strings.ToLower(string(strategy)),
+		Region:       region,
+	}
+
+	s := &Script{Strategy: strategy}
+	for _, t := range []struct {
+		name   string
+		script *string
+	}{
+		{data.Name + ".sh", &s.Apply},
+		{data.Name + "-revert.sh", &s.Revert},
+	} {
+		var buf bytes.Buffer
+		if err := scripts.ExecuteTemplate(&buf, t.name, data); err != nil {
+			return nil, err
+		}
+		*t.script = strings.TrimSpace(buf.String()) + "\n"
+	}
+
+	if params.Duration > 0 {
+		// The name of the file must not match the processes killed by
+		// the script
+		revertFile := fmt.Sprintf("/var/tmp/chaosmonkey-revert-%s.sh", data.Name)
+		s.Apply += fmt.Sprintf("cat > %s <<'CHAOSMONKEY'\n%srm -f %s\nCHAOSMONKEY\n"+
+			"nohup sh -c 'sleep %d; sh %s' >/dev/null 2>&1 &\n",
+			revertFile, s.Revert, revertFile, int(params.Duration.Seconds()), revertFile)
+	}
+	return s, nil
+}
+
+// Interval for polling the status of commands in RunCommand
+var commandPollInterval = 2 * time.Second
+
+// RunCommand runs the shell script on the instance via SSM Run Command and
+// waits for it to finish. It returns the ID of the command, also if the command
+// failed. The instance must run the SSM agent and have an instance profile
+// allowing it to be managed by SSM.
+func (c *Client) RunCommand(ctx context.Context, instanceID, script, comment string) (string, error) {
+	svc, err := c.ssmAPI()
+	if err != nil {
+		return "", err
+	}
+	out, err := svc.SendCommandWithContext(ctx, &ssm.SendCommandInput{
+		DocumentName: aws.String("AWS-RunShellScript"),
+		InstanceIds:  []*string{aws.String(instanceID)},
+		Parameters:   map[string][]*string{"commands": {aws.String(script)}},
+		Comment:      aws.String(comment),
+	})
+	if err != nil {
+		return "", err
+	}
+	id := aws.StringValue(out.Command.CommandId)
+
+	ticker := time.NewTicker(commandPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return id, ctx.Err()
+		case <-ticker.C:
+		}
+
+		inv, err := svc.GetCommandInvocationWithContext(ctx, &ssm.GetCommandInvocationInput{
+			CommandId:  aws.String(id),
+			InstanceId: aws.String(instanceID),
+		})
+		if err != nil {
+			// The invocation may not exist right after sending the command
+			if e, ok := err.(awserr.Error); ok && e.Code() == "InvocationDoesNotExist" {
+				continue
+			}
+			return id, err
+		}
+
+		switch status := aws.StringValue(inv.Status); status {
+		case ssm.CommandInvocationStatusSuccess:
+			return id, nil
+		case ssm.CommandInvocationStatusPending,
+			ssm.CommandInvocationStatusInProgress,
+			ssm.CommandInvocationStatusDelayed:
+		default:
+			if msg := strings.TrimSpace(aws.StringValue(inv.StandardErrorContent)); msg != "" {
+				return id, fmt.Errorf("command %s on instance %s: %s: %s", id, instanceID, status, msg)
+			}
+			return id, fmt.Errorf("command %s on instance %s: %s", id, instanceID, status)
+		}
+	}
+}
+
+// runScript applies the chaos strategy to the instance via RunCommand,
+// recording the command and the script reverting it in the fault.
+func (c *Client) runScript(ctx context.Context, f *Fault, params ScriptParams) error {
+	script, err := NewScript(f.Strategy, c.Region, params)
+	if err != nil {
+		return err
+	}
+	f.RevertScript = script.Revert
+	f.CommandID, err = c.RunCommand(ctx, f.InstanceID, script.Apply, "chaosmonkey "+string(f.Strategy))
+	if f.CommandID == "" {
+		// Nothing was run on the instance
+		f.RevertScript = ""
+	}
+	return err
+}
+
+func (c *Client) ssmAPI() (ssmiface.SSMAPI, error) {
+	if c.ssm != nil {
+		return c.ssm, nil
+	}
+	sess, err := c.newSession()
+	if err != nil {
+		return nil, err
+	}
+	return ssm.New(sess), nil
+}
@@ -0,0 +1,245 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+)
+
+// fakeSSM is a stand-in for the SSM API. Each command goes through the given
+// statuses, the first poll failing as the invocation does not exist yet.
+type fakeSSM struct {
+	ssmiface.SSMAPI
+	statuses []string
+	stderr   string
+	scripts  []string
+	polls    map[string]int
+}
+
+func (f *fakeSSM) SendCommandWithContext(ctx aws.Context, in *ssm.SendCommandInput, opts ...request.Option) (*ssm.SendCommandOutput, error) {
+	if aws.StringValue(in.DocumentName) != "AWS-RunShellScript" {
+		return nil, fmt.Errorf("unexpected document %s", aws.StringValue(in.DocumentName))
+	}
+	f.scripts = append(f.scripts, aws.StringValue(in.Parameters["commands"][0]))
+	id := fmt.Sprintf("cmd-%d", len(f.scripts))
+	return &ssm.SendCommandOutput{Command: &ssm.Command{CommandId: aws.String(id)}}, nil
+}
+
+func (f *fakeSSM) GetCommandInvocationWithContext(ctx aws.Context, in *ssm.GetCommandInvocationInput, opts ...request.Option) (*ssm.GetCommandInvocationOutput, error) {
+	if f.polls == nil {
+		f.polls = make(map[string]int)
+	}
+	id := aws.StringValue(in.CommandId)
+	n := f.polls[id]
+	f.polls[id]++
+	if n == 0 {
+		return nil, awserr.New("InvocationDoesNotExist", "invocation does not exist", nil)
+	}
+	if n > len(f.statuses) {
+		n = len(f.statuses)
+	}
+	return &ssm.GetCommandInvocationOutput{
+		Status:               aws.String(f.statuses[n-1]),
+		StandardErrorContent: aws.String(f.stderr),
+	}, nil
+}
+
+func init() {
+	commandPollInterval = time.Millisecond
+}
+
+func TestNewScript(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not found")
+	}
+
+	for _, s := range chaosmonkey.Strategies {
+		switch s {
+		case chaosmonkey.StrategyShutdownInstance,
+			chaosmonkey.StrategyBlockAllNetworkTraffic,
+			chaosmonkey.StrategyDetachVolumes:
+			if HasScript(s) {
+				t.Errorf("unexpected script for %s", s)
+			}
+			continue
+		}
+		for _, params := range []ScriptParams{{}, {Duration: time.Minute, TargetCIDR: "10.1.0.0/16"}} {
+			script, err := NewScript(s, "eu-west-1", params)
+			if err != nil {
+				t.Fatalf("%s: %s", s, err)
+			}
+			// Check the syntax of the scripts
+			for _, code := range []string{script.Apply, script.Revert} {
+				cmd := exec.Command(sh, "-n")
+				cmd.Stdin = strings.NewReader(code)
+				if out, err := cmd.CombinedOutput(); err != nil {
+					t.Errorf("invalid script for %s: %s\n%s\n%s", s, err, out, code)
+				}
+			}
+			if params.Duration > 0 && !strings.Contains(script.Apply, "sleep 60;") {
+				t.Errorf("%s: reversal not scheduled:\n%s", s, script.Apply)
+			}
+		}
+	}
+}
+
+func TestNewScriptParams(t *testing.T) {
+	script, err := NewScript(chaosmonkey.StrategyNetworkLoss, "", ScriptParams{TargetCIDR: "10.1.0.0/16"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"netem loss 7%", "match ip dst 10.1.0.0/16"} {
+		if !strings.Contains(script.Apply, want) {
+			t.Errorf("script does not contain %q:\n%s", want, script.Apply)
+		}
+	}
+
+	script, err = NewScript(chaosmonkey.StrategyNetworkLatency, "", ScriptParams{Latency: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(script.Apply, `root netem delay 200ms 100ms`) {
+		t.Errorf("unexpected script:\n%s", script.Apply)
+	}
+
+	script, err = NewScript(chaosmonkey.StrategyNullRoute, "", ScriptParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ip route add blackhole 10.0.0.0/8\n"; !strings.HasSuffix(script.Apply, want) {
+		t.Errorf("unexpected script:\n%s", script.Apply)
+	}
+
+	script, err = NewScript(chaosmonkey.StrategyFailEC2, "eu-west-1", ScriptParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(script.Apply, "region=eu-west-1") || !strings.Contains(script.Revert, "chaosmonkey-failec2") {
+		t.Errorf("unexpected scripts:\n%s\n%s", script.Apply, script.Revert)
+	}
+
+	for _, params := range []ScriptParams{
+		{Intensity: 101},
+		{Duration: -time.Second},
+		{TargetCIDR: "10.0.0.1"},
+	} {
+		if _, err := NewScript(chaosmonkey.StrategyBurnCPU, "", params); err == nil {
+			t.Errorf("expected error for %+v", params)
+		}
+	}
+	if _, err := NewScript("KillNginx", "", ScriptParams{}); !errors.Is(err, chaosmonkey.ErrUnsupportedStrategy) {
+		t.Errorf("expected ErrUnsupportedStrategy, got %v", err)
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	f := newFakeEC2(&ec2.Instance{InstanceId: aws.String("i-1")})
+	svc := &fakeSSM{statuses: []string{ssm.CommandInvocationStatusInProgress, ssm.CommandInvocationStatusSuccess}}
+	c := newTestClient(f)
+	c.ssm = svc
+	c.ScriptParams = ScriptParams{Intensity: 50}
+
+	fault, err := c.InjectFault(context.Background(), "web", chaosmonkey.StrategyBurnCPU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fault.CommandID != "cmd-1" || !strings.Contains(fault.RevertScript, "pkill") {
+		t.Fatalf("unexpected fault %+v", fault)
+	}
+	if !strings.Contains(svc.scripts[0], "* 50 + 99") {
+		t.Errorf("unexpected script:\n%s", svc.scripts[0])
+	}
+	if svc.polls["cmd-1"] != 3 {
+		t.Errorf("want 3 polls, got %d", svc.polls["cmd-1"])
+	}
+
+	if err := c.RevertFault(context.Background(), fault); err != nil {
+		t.Fatal(err)
+	}
+	if len(svc.scripts) != 2 || svc.scripts[1] != fault.RevertScript {
+		t.Fatalf("revert script was not run: %q", svc.scripts)
+	}
+}
+
+func TestInjectFaultFor(t *testing.T) {
+	f := newFakeEC2(&ec2.Instance{InstanceId: aws.String("i-1")})
+	svc := &fakeSSM{statuses: []string{ssm.CommandInvocationStatusSuccess}}
+	c := newTestClient(f)
+	c.ssm = svc
+	var faults []*Fault
+	c.OnFault = func(fault *Fault) { faults = append(faults, fault) }
+
+	_, err := c.TriggerRequestContext(context.Background(), &chaosmonkey.APIRequest{
+		GroupName: "web",
+		ChaosType: string(chaosmonkey.StrategyNetworkLatency),
+		Duration:  5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(faults) != 1 || faults[0].Duration != 5*time.Minute {
+		t.Fatalf("unexpected faults %+v", faults)
+	}
+	if want := faults[0].TriggeredAt.Add(5 * time.Minute); !faults[0].Expires().Equal(want) {
+		t.Errorf("want expiry %s, got %s", want, faults[0].Expires())
+	}
+	// The instance reverts the script itself in case the caller doesn't
+	if !strings.Contains(svc.scripts[0], "sleep 300;") {
+		t.Errorf("reversal not scheduled:\n%s", svc.scripts[0])
+	}
+
+	fault, err := c.InjectFault(context.Background(), "web", chaosmonkey.StrategyNetworkLatency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fault.Expires().IsZero() || strings.Contains(svc.scripts[1], "sleep") {
+		t.Errorf("unexpected expiry of fault without duration")
+	}
+
+	for _, strategy := range []chaosmonkey.Strategy{"", chaosmonkey.StrategyShutdownInstance} {
+		if _, err := c.InjectFaultFor(context.Background(), "web", strategy, time.Minute); !errors.Is(err, chaosmonkey.ErrUnsupportedDuration) {
+			t.Errorf("expected ErrUnsupportedDuration, got %v", err)
+		}
+	}
+	if len(f.terminated) != 0 {
+		t.Fatal("instance must not be terminated")
+	}
+}
+
+func TestRunScriptFailed(t *testing.T) {
+	f := newFakeEC2(&ec2.Instance{InstanceId: aws.String("i-1")})
+	svc := &fakeSSM{statuses: []string{ssm.CommandInvocationStatusFailed}, stderr: "tc: command not found\n"}
+	c := newTestClient(f)
+	c.ssm = svc
+
+	fault, err := c.InjectFault(context.Background(), "web", chaosmonkey.StrategyNetworkLoss)
+	if err == nil || !strings.HasSuffix(err.Error(), "Failed: tc: command not found") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// The script may have been applied partially
+	if fault == nil || !fault.Reversible() {
+		t.Fatalf("expected reversible fault, got %+v", fault)
+	}
+
+	c.ScriptParams.Intensity = 200
+	if _, err := c.InjectFault(context.Background(), "web", chaosmonkey.StrategyNetworkLoss); err == nil {
+		t.Fatal("expected error")
+	}
+	if len(svc.scripts) != 1 {
+		t.Fatal("script must not be run with invalid parameters")
+	}
+}
@@ -0,0 +1,792 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/mlafeldt/chaosmonkey/audit"
+	"github.com/mlafeldt/chaosmonkey/aws"
+	"github.com/mlafeldt/chaosmonkey/experiment"
+	"github.com/mlafeldt/chaosmonkey/journal"
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+)
+
+// command is a subcommand of the CLI.
+type command struct {
+	// Name of the command, which may consist of multiple words, e.g.
+	// "state wipe"
+	name string
+
+	// Positional arguments shown in the usage, e.g. "FILE"
+	args string
+
+	// One-line description shown in the list of commands
+	summary string
+
+	// Detailed description shown in the help of the command
+	description string
+
+	// Function defining the flags of the command and returning the
+	// function that executes it with the remaining arguments
+	setup func(fs *flag.FlagSet) func(args []string)
+}
+
+var commands = []*command{
+	{
+		name:    "trigger",
+		summary: "Trigger chaos events in an auto scaling group",
+		description: "Trigger one or more chaos events in an auto scaling group, optionally with a\n" +
+			"probability per event, and print the affected instances.",
+		setup: setupTrigger,
+	},
+	{
+		name:    "run",
+		args:    "FILE",
+		summary: "Run an experiment defined in a YAML or JSON file",
+		setup:   setupRun,
+	},
+	{
+		name:    "rollback",
+		summary: "Revert faults injected by the native AWS backend",
+		description: "Revert faults injected by the native AWS backend that are still pending in the\n" +
+			"journal, most recent first, e.g. after a run was killed. Shut down instances\n" +
+			"cannot be restored.",
+		setup: setupRollback,
+	},
+	{
+		name:    "events",
+		summary: "List or watch chaos events",
+		setup:   setupEvents,
+	},
+	{
+		name:    "strategies",
+		summary: "List chaos strategies",
+		setup:   setupStrategies,
+	},
+	{
+		name:    "groups",
+		summary: "List auto scaling groups",
+		description: "List all auto scaling groups of the AWS account. AWS credentials are read from\n" +
+			"the environment, e.g. AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_ROLE.",
+		setup: setupGroups,
+	},
+	{
+		name:    "state wipe",
+		args:    "DOMAIN",
+		summary: "Wipe state of Chaos Monkey by deleting its SimpleDB domain",
+		description: "Wipe state of Chaos Monkey by deleting its SimpleDB domain, which is named\n" +
+			"SIMIAN_ARMY by default. Requires a restart of Chaos Monkey.",
+		setup: setupStateWipe,
+	},
+	{
+		name:    "audit",
+		summary: "Query the audit log",
+		setup:   setupAudit,
+	},
+	{
+		name:    "version",
+		summary: "Show program version",
+		setup:   setupVersion,
+	},
+}
+
+// findCommand returns the command named by the leading arguments, along with
+// the remaining arguments, or nil if there is no such command.
+func findCommand(args []string) (*command, []string) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) < len(words) {
+			continue
+		}
+		if strings.Join(args[:len(words)], " ") == cmd.name {
+			return cmd, args[len(words):]
+		}
+	}
+	return nil, args
+}
+
+// newFlagSet returns the flag set of the command, including its usage.
+func (cmd *command) newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("chaosmonkey "+cmd.name, flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		usage := "chaosmonkey " + cmd.name + " [flags]"
+		if cmd.args != "" {
+			usage += " " + cmd.args
+		}
+		fmt.Fprintf(w, "Usage: %s\n\n", usage)
+		description := cmd.description
+		if description == "" {
+			description = cmd.summary + "."
+		}
+		fmt.Fprintf(w, "%s\n\nFlags:\n", description)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// execute parses the flags of the command and runs it.
+func (cmd *command) execute(args []string) {
+	fs := cmd.newFlagSet()
+	run := cmd.setup(fs)
+	fs.Parse(args)
+	run(fs.Args())
+}
+
+// usage prints the list of commands.
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintf(w, "Usage: chaosmonkey COMMAND [flags] [args]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nRun 'chaosmonkey COMMAND -h' for the flags of a command.\n")
+}
+
+// clientOptions are the flags used to connect to the Chaos Monkey API.
+type clientOptions struct {
+	endpoint, region, username, password, profileName *string
+	passwordFile, passwordCommand                     *string
+	auth                                              chaosmonkey.AuthOptions
+
+	profile *chaosmonkey.Profile
+	loaded  bool
+}
+
+func addClientFlags(fs *flag.FlagSet) *clientOptions {
+	o := &clientOptions{
+		endpoint:        fs.String("endpoint", "", "Address and port of Chaos Monkey API server"),
+		region:          fs.String("region", "", "Name of AWS region (ignored by vanilla Chaos Monkey)"),
+		username:        fs.String("username", "", "Username for HTTP basic authentication"),
+		password:        fs.String("password", "", "Password for HTTP basic authentication (visible to other users, prefer -password-file)"),
+		passwordFile:    fs.String("password-file", "", "Read password for HTTP basic authentication from file, or stdin if '-'"),
+		passwordCommand: fs.String("password-command", "", "Credential helper command printing password for HTTP basic authentication"),
+		profileName:     fs.String("profile", "", "Name of profile in "+chaosmonkey.DefaultConfigFile()+" (default $CHAOSMONKEY_PROFILE or default-profile)"),
+	}
+	fs.StringVar(&o.auth.Token, "token", "", "Bearer token for authentication (visible to other users, prefer -token-command)")
+	fs.StringVar(&o.auth.TokenCommand, "token-command", "", "Command printing bearer token for authentication")
+	fs.DurationVar(&o.auth.TokenTTL, "token-ttl", chaosmonkey.DefaultTokenTTL, "Time to cache tokens of -token-command without expiry")
+	fs.StringVar(&o.auth.ClientCert, "client-cert", "", "Path of TLS client certificate for mutual TLS")
+	fs.StringVar(&o.auth.ClientKey, "client-key", "", "Path of private key of -client-cert")
+	fs.StringVar(&o.auth.CACert, "ca-cert", "", "Path of CA certificates used to verify the server")
+	return o
+}
+
+// loadProfile returns the selected profile, or nil if there is none.
+func (o *clientOptions) loadProfile() *chaosmonkey.Profile {
+	if !o.loaded {
+		p, err := chaosmonkey.LoadProfile(*o.profileName)
+		if err != nil {
+			abort("failed to load profile: %s", err)
+		}
+		o.profile, o.loaded = p, true
+	}
+	return o.profile
+}
+
+// config returns the client configuration of the selected profile, overridden
+// by the flags.
+func (o *clientOptions) config() chaosmonkey.Config {
+	c, err := chaosmonkey.LoadConfig(*o.profileName)
+	if err != nil {
+		abort("failed to load profile: %s", err)
+	}
+	p := o.loadProfile()
+	if p == nil || p.UserAgent == "" {
+		c.UserAgent = fmt.Sprintf("chaosmonkey Go client %s", Version)
+	}
+	if p == nil || p.Timeout == 0 {
+		c.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if *o.endpoint != "" {
+		c.Endpoint = *o.endpoint
+	}
+	if *o.region != "" {
+		c.Region = *o.region
+	}
+	if *o.username != "" {
+		c.Username = *o.username
+	}
+	if *o.password != "" {
+		c.Password = *o.password
+	}
+	if *o.passwordFile != "" || *o.passwordCommand != "" {
+		c.Password, c.PasswordFile, c.PasswordCommand = "", *o.passwordFile, *o.passwordCommand
+	}
+	if *o.username != "" || *o.password != "" || *o.passwordFile != "" || *o.passwordCommand != "" {
+		c.Authenticator = nil
+	}
+	a, err := o.auth.Authenticator()
+	if err != nil {
+		abort("%s", err)
+	}
+	if a != nil {
+		c.Authenticator = a
+	}
+	tls, err := o.auth.TLS()
+	if err != nil {
+		abort("%s", err)
+	}
+	if tls != nil {
+		c.TLS = tls
+	}
+	// Resolve the password once, rather than for every client created
+	if c.Authenticator == nil {
+		if err := c.ResolvePassword(); err != nil {
+			abort("failed to get password: %s", err)
+		}
+	}
+	return *c
+}
+
+func (o *clientOptions) client() *chaosmonkey.Client {
+	config := o.config()
+	client, err := chaosmonkey.NewClient(&config)
+	if err != nil {
+		abort("%s", err)
+	}
+	return client
+}
+
+// outputOptions are the flags controlling how lists are printed.
+type outputOptions struct {
+	format, template *string
+}
+
+func addOutputFlags(fs *flag.FlagSet, example string) *outputOptions {
+	return &outputOptions{
+		format:   fs.String("output", "table", "Output format: "+strings.Join(outputFormats, ", ")),
+		template: fs.String("template", "", "Go template for -output template, e.g. '"+example+"'"),
+	}
+}
+
+// setup initializes the printer used for all lists printed to stdout.
+func (o *outputOptions) setup() {
+	var err error
+	if output, err = newPrinter(os.Stdout, *o.format, *o.template); err != nil {
+		abort("%s", err)
+	}
+}
+
+// auditOptions are the flags controlling the audit log.
+type auditOptions struct {
+	path   *string
+	syslog *bool
+}
+
+func addAuditFlags(fs *flag.FlagSet, write bool) *auditOptions {
+	o := &auditOptions{
+		path: fs.String("audit-log", audit.DefaultPath(), "Path of audit log recording triggered chaos events, wiped state, and rollbacks (empty to disable)"),
+	}
+	if write {
+		o.syslog = fs.Bool("audit-syslog", false, "Also send audit log entries to syslog")
+	}
+	return o
+}
+
+func (o *auditOptions) open(endpoint, region string) *auditRecorder {
+	return openAuditLog(*o.path, *o.syslog, endpoint, region)
+}
+
+// filterOptions are the flags used to filter chaos events and audit log
+// entries.
+type filterOptions struct {
+	group, strategy, since, until *string
+	limit                         *int
+}
+
+func addFilterFlags(fs *flag.FlagSet, what string) *filterOptions {
+	return &filterOptions{
+		group:    fs.String("filter-group", "", "Only list "+what+" of auto scaling groups matching glob pattern"),
+		strategy: fs.String("filter-strategy", "", "Only list "+what+" of comma-separated chaos strategies"),
+		since:    fs.String("since", "", "Only list "+what+" since time (RFC 3339) or duration ago"),
+		until:    fs.String("until", "", "Only list "+what+" before time (RFC 3339) or duration ago"),
+		limit:    fs.Int("limit", 0, "Maximum number of "+what+" to list"),
+	}
+}
+
+func (o *filterOptions) strategies() []chaosmonkey.Strategy {
+	var strategies []chaosmonkey.Strategy
+	if *o.strategy != "" {
+		for _, s := range strings.Split(*o.strategy, ",") {
+			strategies = append(strategies, chaosmonkey.Strategy(s))
+		}
+	}
+	return strategies
+}
+
+func (o *filterOptions) timeWindow() (since, until time.Time) {
+	var err error
+	if since, err = parseTime(*o.since); err != nil {
+		abort("invalid value for -since: %s", err)
+	}
+	if until, err = parseTime(*o.until); err != nil {
+		abort("invalid value for -until: %s", err)
+	}
+	return since, until
+}
+
+// Backends selectable via -backend
+const (
+	backendSimianArmy = "simianarmy"
+	backendAWS        = "aws"
+)
+
+// runnerOptions are the flags used to configure the execution of chaos events.
+type runnerOptions struct {
+	fs     *flag.FlagSet
+	client *clientOptions
+	audit  *auditOptions
+
+	backend      *string
+	script       aws.ScriptParams
+	journal      *string
+	rollback     *bool
+	dryRun       *bool
+	minInService experiment.Floor
+	capacityWait *time.Duration
+	probes       probeList
+
+	waitRecovery    *bool
+	recoveryTimeout *time.Duration
+
+	reportDir *string
+
+	// Journal recording the faults of the run, if any
+	faults *faultJournal
+
+	webhooks       stringList
+	webhookHeaders stringList
+	webhookSecret  *string
+	webhookFormat  *string
+	webhookRetries *int
+}
+
+func addRunnerFlags(fs *flag.FlagSet) *runnerOptions {
+	o := &runnerOptions{
+		fs:     fs,
+		client: addClientFlags(fs),
+		audit:  addAuditFlags(fs, true),
+
+		backend:      fs.String("backend", backendSimianArmy, "Backend triggering chaos events: simianarmy (Chaos Monkey API) or aws (AWS API)"),
+		journal:      fs.String("journal", journal.DefaultPath(), "Path of journal recording how to revert faults injected by -backend aws (empty to disable)"),
+		rollback:     fs.Bool("rollback", true, "Roll back faults if interrupted or a probe fails (-backend aws)"),
+		dryRun:       fs.Bool("dry-run", false, "Only print requests instead of triggering chaos events"),
+		capacityWait: fs.Duration("capacity-wait", 0, "Time to wait for auto scaling group to regain capacity before aborting"),
+
+		waitRecovery:    fs.Bool("wait-recovery", false, "Wait for auto scaling group to recover between chaos events"),
+		recoveryTimeout: fs.Duration("recovery-timeout", 10*time.Minute, "Time to wait for auto scaling group to recover before aborting"),
+
+		reportDir: fs.String("report-dir", "", "Write report of chaos run as JSON, Markdown, and HTML to directory"),
+
+		webhookSecret:  fs.String("webhook-secret", "", "Secret used to sign webhook payloads with HMAC-SHA256"),
+		webhookFormat:  fs.String("webhook-format", experiment.WebhookFormatJSON, "Payload format of webhooks: json or slack"),
+		webhookRetries: fs.Int("webhook-retries", 3, "Number of times to retry failed webhook requests"),
+	}
+	fs.IntVar(&o.script.Intensity, "intensity", 0, "Intensity of chaos events run via SSM in percent, e.g. share of CPUs to burn (-backend aws)")
+	fs.DurationVar(&o.script.Latency, "latency", time.Second, "Delay added to network packets by NetworkLatency (-backend aws)")
+	fs.StringVar(&o.script.TargetCIDR, "target-cidr", "", "Network affected by network strategies and NullRoute (-backend aws)")
+	fs.Var(&o.probes, "probe", "Steady-state probe, e.g. 'http=http://example.com/health;expect-status=200' (repeatable)")
+	fs.Var(&o.webhooks, "webhook", "URL to send notifications about the chaos run to (repeatable)")
+	fs.Var(&o.webhookHeaders, "webhook-header", "HTTP header to add to webhook requests, e.g. 'Authorization: Bearer token' (repeatable)")
+	fs.Var(&o.minInService, "min-in-service", "Minimum number or percentage of instances in service to keep, e.g. 2 or 50%")
+	return o
+}
+
+// verbose reports whether any option is set whose effect is logged.
+func (o *runnerOptions) verbose() bool {
+	return *o.dryRun || !o.minInService.IsZero() || *o.waitRecovery || len(o.probes) > 0 ||
+		*o.reportDir != "" || len(o.webhooks) > 0
+}
+
+// isSet reports whether the flag with the given name was passed.
+func (o *runnerOptions) isSet(name string) bool {
+	set := false
+	o.fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// applyLimits uses the safety limits of the selected profile as defaults for
+// flags that were not passed.
+func (o *runnerOptions) applyLimits() {
+	p := o.client.loadProfile()
+	if p == nil {
+		return
+	}
+	if p.Limits.MinInService != "" && !o.isSet("min-in-service") {
+		floor, err := experiment.ParseFloor(p.Limits.MinInService)
+		if err != nil {
+			abort("invalid min-in-service in profile: %s", err)
+		}
+		o.minInService = floor
+	}
+	if p.Limits.CapacityWait > 0 && !o.isSet("capacity-wait") {
+		*o.capacityWait = p.Limits.CapacityWait
+	}
+	if p.Limits.RecoveryTimeout > 0 && !o.isSet("recovery-timeout") {
+		*o.recoveryTimeout = p.Limits.RecoveryTimeout
+	}
+}
+
+// checkCount aborts if count exceeds the maximum number of chaos events
+// allowed by the selected profile.
+func (o *runnerOptions) checkCount(count int, what string) {
+	if p := o.client.loadProfile(); p != nil && p.Limits.MaxCount > 0 && count > p.Limits.MaxCount {
+		abort("%s exceeds the maximum of %d chaos event(s) allowed by profile", what, p.Limits.MaxCount)
+	}
+}
+
+// checkDuration aborts if chaos events of the strategy cannot be reverted after
+// the given duration, as the backend or the strategy does not support it.
+func (o *runnerOptions) checkDuration(d time.Duration, strategy chaosmonkey.Strategy, what string) {
+	if d == 0 {
+		return
+	}
+	if *o.backend != backendAWS {
+		abort("%s requires -backend %s", what, backendAWS)
+	}
+	if strategy == "" || strategy == chaosmonkey.StrategyShutdownInstance {
+		abort("%s cannot be used with %s, which cannot be reverted", what, chaosmonkey.StrategyShutdownInstance)
+	}
+}
+
+// runner returns the runner configured by the options, along with the audit
+// log recording its chaos events, if any. Faults injected by the native AWS
+// backend are recorded in the journal of the options.
+func (o *runnerOptions) runner() (*experiment.Runner, *auditRecorder) {
+	o.applyLimits()
+	runner := &experiment.Runner{
+		Config:          o.client.config(),
+		DryRun:          *o.dryRun,
+		MinInService:    o.minInService,
+		CapacityWait:    *o.capacityWait,
+		Probes:          o.probes,
+		CaptureCapacity: *o.reportDir != "",
+	}
+	switch *o.backend {
+	case backendSimianArmy:
+		for _, name := range []string{"intensity", "latency", "target-cidr", "journal", "rollback"} {
+			if o.isSet(name) {
+				abort("-%s requires -backend %s", name, backendAWS)
+			}
+		}
+	case backendAWS:
+		if err := o.script.Validate(); err != nil {
+			abort("%s", err)
+		}
+		runner.Backend = func(region string) chaosmonkey.Backend {
+			c := aws.NewClient(region)
+			c.ScriptParams = o.script
+			c.OnFault = o.faults.record
+			return c
+		}
+	default:
+		abort("invalid value for -backend: %s", *o.backend)
+	}
+	if *o.waitRecovery {
+		runner.RecoveryTimeout = *o.recoveryTimeout
+	}
+	if len(o.webhooks) > 0 {
+		headers, err := parseHeaders(o.webhookHeaders)
+		if err != nil {
+			abort("invalid value for -webhook-header: %s", err)
+		}
+		for _, url := range o.webhooks {
+			runner.Notifiers = append(runner.Notifiers, &experiment.Webhook{
+				URL:     url,
+				Format:  *o.webhookFormat,
+				Headers: headers,
+				Secret:  *o.webhookSecret,
+				Retries: *o.webhookRetries,
+			})
+		}
+	}
+
+	var auditor *auditRecorder
+	if !*o.dryRun {
+		endpoint := runner.Config.Endpoint
+		if runner.Backend != nil {
+			endpoint = ""
+		}
+		auditor = o.audit.open(endpoint, runner.Config.Region)
+		if auditor != nil {
+			runner.Notifiers = append(runner.Notifiers, auditor)
+		}
+		if runner.Backend != nil {
+			o.faults = openJournal(*o.journal, *o.rollback, auditor)
+		}
+	}
+	return runner, auditor
+}
+
+func setupTrigger(fs *flag.FlagSet) func([]string) {
+	var (
+		opts = addRunnerFlags(fs)
+		out  = addOutputFlags(fs, "{{.InstanceID}}")
+
+		group        = fs.String("group", "", "Name of auto scaling group, see 'chaosmonkey groups' (required)")
+		strategyName = fs.String("strategy", "", "Chaos strategy to use, see 'chaosmonkey strategies'")
+
+		count       = fs.Int("count", 1, "Number of times to trigger chaos event")
+		interval    = fs.Duration("interval", 5*time.Second, "Time to wait between chaos events")
+		duration    = fs.Duration("duration", 0, "Time after which to revert each chaos event, e.g. 5m (-backend aws)")
+		probability = fs.Float64("probability", 1.0, "Probability of chaos events")
+	)
+	return func(args []string) {
+		if len(args) > 0 {
+			abort("trigger expects no arguments, but %d given", len(args))
+		}
+		if *group == "" {
+			abort("-group is required")
+		}
+		if *count < 1 {
+			abort("-count must be at least 1")
+		}
+		if *duration < 0 {
+			abort("invalid value for -duration: %s", *duration)
+		}
+		var strategy chaosmonkey.Strategy
+		if *strategyName != "" {
+			var err error
+			if strategy, err = chaosmonkey.ParseStrategy(*strategyName); err != nil {
+				abort("%s, see 'chaosmonkey strategies'", err)
+			}
+		}
+		opts.checkDuration(*duration, strategy, "-duration")
+		opts.checkCount(*count, "-count")
+		out.setup()
+
+		runner, auditor := opts.runner()
+		defer auditor.close()
+		defer opts.faults.close()
+		if auditor != nil {
+			auditor.base.Group = *group
+			auditor.base.Strategy = strategy
+		}
+		if opts.verbose() {
+			runner.Log = os.Stderr
+		}
+		summary := runExperiment(runner, &experiment.Experiment{
+			Steps: []experiment.Step{{
+				Group:       *group,
+				Strategy:    strategy,
+				Count:       *count,
+				Interval:    *interval,
+				Duration:    *duration,
+				Probability: probability,
+			}},
+		}, *opts.reportDir, opts.faults, auditor)
+		if skipped := summary.Skipped(); skipped > 0 {
+			fmt.Fprintf(os.Stderr, "Skipped %d chaos event(s) with probability of %f\n", skipped, *probability)
+		}
+	}
+}
+
+func setupRun(fs *flag.FlagSet) func([]string) {
+	var (
+		opts = addRunnerFlags(fs)
+		out  = addOutputFlags(fs, "{{.InstanceID}}")
+	)
+	return func(args []string) {
+		if len(args) != 1 {
+			abort("run expects an experiment file as argument")
+		}
+		e, err := experiment.Load(args[0])
+		if err != nil {
+			abort("failed to load experiment: %s", err)
+		}
+		for i, s := range e.Steps {
+			opts.checkDuration(s.Duration, s.Strategy, fmt.Sprintf("duration of step %d", i+1))
+			opts.checkCount(s.Count, fmt.Sprintf("count of step %d", i+1))
+		}
+		out.setup()
+
+		runner, auditor := opts.runner()
+		defer auditor.close()
+		defer opts.faults.close()
+		runner.Log = os.Stderr
+		runExperiment(runner, e, *opts.reportDir, opts.faults, auditor)
+	}
+}
+
+func setupRollback(fs *flag.FlagSet) func([]string) {
+	var (
+		opts = addAuditFlags(fs, true)
+		out  = addOutputFlags(fs, "{{.RunID}}")
+
+		path   = fs.String("journal", journal.DefaultPath(), "Path of journal recording faults")
+		runID  = fs.String("run-id", "", "Only revert faults of run with ID (default all runs)")
+		dryRun = fs.Bool("dry-run", false, "Only list faults instead of reverting them")
+	)
+	return func(args []string) {
+		if len(args) > 0 {
+			abort("rollback expects no arguments, but %d given", len(args))
+		}
+		if *path == "" {
+			abort("-journal is required")
+		}
+		if *dryRun {
+			out.setup()
+			pending, err := journal.Pending(*path, *runID)
+			if err != nil {
+				abort("failed to read journal: %s", err)
+			}
+			printJournalEntries(pending)
+			return
+		}
+
+		auditor := opts.open("", "")
+		defer auditor.close()
+		if err := rollbackFaults(*path, *runID, auditor); err != nil {
+			abort("%s", err)
+		}
+	}
+}
+
+func setupEvents(fs *flag.FlagSet) func([]string) {
+	var (
+		client = addClientFlags(fs)
+		filter = addFilterFlags(fs, "events")
+		out    = addOutputFlags(fs, "{{.InstanceID}}")
+
+		watch    = fs.Bool("watch", false, "Watch for new chaos events until interrupted")
+		interval = fs.Duration("interval", 5*time.Second, "Time to wait between polls of -watch")
+	)
+	return func(args []string) {
+		if len(args) > 0 {
+			abort("events expects no arguments, but %d given", len(args))
+		}
+		if *interval <= 0 {
+			abort("-interval must be positive")
+		}
+		out.setup()
+
+		query := chaosmonkey.EventQuery{
+			Group:      *filter.group,
+			Strategies: filter.strategies(),
+			Limit:      *filter.limit,
+		}
+		query.Since, query.Until = filter.timeWindow()
+
+		c := client.client()
+		if *watch {
+			watchEvents(c, query, *interval)
+			return
+		}
+		events, err := c.QueryEvents(context.Background(), query)
+		if err != nil {
+			abort("%s", err)
+		}
+		printEvents(events...)
+	}
+}
+
+func setupStrategies(fs *flag.FlagSet) func([]string) {
+	var (
+		out     = addOutputFlags(fs, "{{.}}")
+		details = fs.Bool("details", false, "Show description of strategies and whether they require SSH")
+	)
+	return func(args []string) {
+		if len(args) > 0 {
+			abort("strategies expects no arguments, but %d given", len(args))
+		}
+		out.setup()
+		if *details {
+			printStrategyDetails(chaosmonkey.RegisteredStrategies())
+			return
+		}
+		var strategies []chaosmonkey.Strategy
+		for _, info := range chaosmonkey.RegisteredStrategies() {
+			strategies = append(strategies, info.Strategy)
+		}
+		printStrategies(strategies)
+	}
+}
+
+func setupGroups(fs *flag.FlagSet) func([]string) {
+	var (
+		region = fs.String("region", "", "Name of AWS region")
+		out    = addOutputFlags(fs, "{{.Name}}")
+	)
+	return func(args []string) {
+		if len(args) > 0 {
+			abort("groups expects no arguments, but %d given", len(args))
+		}
+		out.setup()
+		groups, err := aws.NewClient(*region).AutoScalingGroups()
+		if err != nil {
+			abort("failed to get auto scaling groups: %s", err)
+		}
+		listAutoScalingGroups(groups)
+	}
+}
+
+func setupStateWipe(fs *flag.FlagSet) func([]string) {
+	var (
+		region = fs.String("region", "", "Name of AWS region")
+		opts   = addAuditFlags(fs, true)
+	)
+	return func(args []string) {
+		if len(args) != 1 {
+			abort("state wipe expects a SimpleDB domain as argument")
+		}
+		domain := args[0]
+
+		auditor := opts.open("", *region)
+		defer auditor.close()
+		err := aws.NewClient(*region).DeleteSimpleDBDomain(domain)
+		entry := audit.Entry{Command: audit.CommandWipeState, Domain: domain}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		if err := auditor.record(entry); err != nil {
+			abort("%s", err)
+		}
+		if err != nil {
+			abort("failed to wipe state: %s", err)
+		}
+	}
+}
+
+func setupAudit(fs *flag.FlagSet) func([]string) {
+	var (
+		opts   = addAuditFlags(fs, false)
+		filter = addFilterFlags(fs, "entries")
+		out    = addOutputFlags(fs, "{{.Operator}}")
+	)
+	return func(args []string) {
+		if len(args) > 0 {
+			abort("audit expects no arguments, but %d given", len(args))
+		}
+		out.setup()
+
+		query := audit.Query{
+			Group:      *filter.group,
+			Strategies: filter.strategies(),
+			Limit:      *filter.limit,
+		}
+		query.Since, query.Until = filter.timeWindow()
+		entries, err := audit.Read(*opts.path, query)
+		if err != nil {
+			abort("failed to read audit log: %s", err)
+		}
+		printAuditEntries(entries)
+	}
+}
+
+func setupVersion(fs *flag.FlagSet) func([]string) {
+	return func(args []string) {
+		fmt.Printf("chaosmonkey %s %s/%s %s\n", Version,
+			runtime.GOOS, runtime.GOARCH, runtime.Version())
+	}
+}
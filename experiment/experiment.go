@@ -0,0 +1,179 @@
+/*
+Package experiment provides declarative chaos experiments.
+
+An experiment is a list of steps, each of which triggers one or more chaos
+events in an auto scaling group. Experiments are usually defined in YAML or JSON
+files:
+
+	name: Kill web servers
+	probes:
+	  - http: https://example.com/health
+	    expect-body: OK
+	steps:
+	  - group: ExampleAutoScalingGroup
+	    strategy: ShutdownInstance
+	    count: 3
+	    interval: 1m
+	    probability: 0.5
+	    min-in-service: 50%
+	    note: Terminate up to three instances
+	  - group: AnotherAutoScalingGroup
+	    strategy: BurnCpu
+	    pause: 5m
+
+Probes check the steady state of the system before the first and after each
+chaos event. Use a Runner to execute an experiment.
+*/
+package experiment
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+)
+
+// Experiment describes a chaos experiment.
+type Experiment struct {
+	// Name of the experiment
+	Name string `yaml:"name"`
+
+	// Optional description of the experiment
+	Description string `yaml:"description"`
+
+	// Probes checking the steady state of the system before the first and
+	// after each chaos event
+	Probes []Probe `yaml:"probes"`
+
+	// Steps to execute in the given order
+	Steps []Step `yaml:"steps"`
+}
+
+// Step describes chaos events to trigger in an auto scaling group.
+type Step struct {
+	// Name of auto scaling group
+	Group string `yaml:"group"`
+
+	// Chaos strategy to use (Chaos Monkey's default if empty)
+	Strategy chaosmonkey.Strategy `yaml:"strategy"`
+
+	// Number of times to trigger the chaos event (1 by default)
+	Count int `yaml:"count"`
+
+	// Time to wait between chaos events
+	Interval time.Duration `yaml:"interval"`
+
+	// Time after which each chaos event is reverted, which requires a
+	// backend implementing chaosmonkey.RequestBackend that supports it
+	Duration time.Duration `yaml:"duration"`
+
+	// Probability of each chaos event (1.0 by default)
+	Probability *float64 `yaml:"probability"`
+
+	// Optional AWS region (ignored by vanilla Chaos Monkey)
+	Region string `yaml:"region"`
+
+	// Time to wait after the step before executing the next one
+	Pause time.Duration `yaml:"pause"`
+
+	// Optional note printed when executing the step
+	Note string `yaml:"note"`
+
+	// Minimum number of instances in service the group must keep, which
+	// overrides the floor of the runner
+	MinInService Floor `yaml:"min-in-service"`
+}
+
+// Load reads an experiment from a YAML or JSON file and validates it.
+func Load(filename string) (*Experiment, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse parses an experiment in YAML or JSON format and validates it. Chaos
+// strategies of steps are matched case-insensitively, like on the command line.
+func Parse(data []byte) (*Experiment, error) {
+	var e Experiment
+	if err := yaml.UnmarshalStrict(data, &e); err != nil {
+		return nil, err
+	}
+	for i, s := range e.Steps {
+		if s.Strategy == "" {
+			continue
+		}
+		strategy, err := chaosmonkey.ParseStrategy(string(s.Strategy))
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %s", i+1, err)
+		}
+		e.Steps[i].Strategy = strategy
+	}
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Validate returns an error if the experiment is invalid.
+func (e *Experiment) Validate() error {
+	if len(e.Steps) == 0 {
+		return errors.New("experiment has no steps")
+	}
+	for i := range e.Probes {
+		if err := e.Probes[i].Validate(); err != nil {
+			return err
+		}
+	}
+	for i := range e.Steps {
+		if err := e.Steps[i].Validate(); err != nil {
+			return fmt.Errorf("step %d: %s", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Validate returns an error if the step is invalid.
+func (s *Step) Validate() error {
+	if s.Group == "" {
+		return errors.New("group must be specified")
+	}
+	if err := s.Strategy.Validate(); err != nil {
+		return err
+	}
+	if s.Count < 0 {
+		return fmt.Errorf("invalid count %d", s.Count)
+	}
+	if s.Interval < 0 {
+		return fmt.Errorf("invalid interval %s", s.Interval)
+	}
+	if s.Pause < 0 {
+		return fmt.Errorf("invalid pause %s", s.Pause)
+	}
+	if s.Duration < 0 {
+		return fmt.Errorf("invalid duration %s", s.Duration)
+	}
+	if p := s.probability(); p < 0 || p > 1 {
+		return fmt.Errorf("probability %f not between 0 and 1", p)
+	}
+	return nil
+}
+
+func (s *Step) count() int {
+	if s.Count == 0 {
+		return 1
+	}
+	return s.Count
+}
+
+func (s *Step) probability() float64 {
+	if s.Probability == nil {
+		return 1.0
+	}
+	return *s.Probability
+}
@@ -0,0 +1,929 @@
+package experiment_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/mlafeldt/chaosmonkey/aws"
+	"github.com/mlafeldt/chaosmonkey/experiment"
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+	"github.com/mlafeldt/chaosmonkey/lib/chaosmonkeytest"
+)
+
+const exampleYAML = `
+name: Example
+steps:
+  - group: SomeAutoScalingGroup
+    strategy: ShutdownInstance
+    count: 3
+    interval: 1ms
+    probability: 0.5
+    note: Terminate some instances
+  - group: AnotherAutoScalingGroup
+    strategy: BurnCpu
+    region: eu-west-1
+    pause: 1ms
+`
+
+const exampleJSON = `{
+  "name": "Example",
+  "steps": [{"group": "SomeAutoScalingGroup", "strategy": "ShutdownInstance", "interval": "1m"}]
+}`
+
+func TestParse(t *testing.T) {
+	e, err := experiment.Parse([]byte(exampleYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	half := 0.5
+	expected := &experiment.Experiment{
+		Name: "Example",
+		Steps: []experiment.Step{
+			{
+				Group:       "SomeAutoScalingGroup",
+				Strategy:    chaosmonkey.StrategyShutdownInstance,
+				Count:       3,
+				Interval:    time.Millisecond,
+				Probability: &half,
+				Note:        "Terminate some instances",
+			},
+			{
+				Group:    "AnotherAutoScalingGroup",
+				Strategy: chaosmonkey.StrategyBurnCPU,
+				Region:   "eu-west-1",
+				Pause:    time.Millisecond,
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, e); diff != "" {
+		t.Fatal(diff)
+	}
+
+	e, err = experiment.Parse([]byte(exampleJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Steps[0].Interval != time.Minute {
+		t.Fatalf("unexpected interval %s", e.Steps[0].Interval)
+	}
+
+	// Strategies are matched case-insensitively
+	e, err = experiment.Parse([]byte(`steps: [{group: g, strategy: burncpu}, {group: g}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Steps[0].Strategy != chaosmonkey.StrategyBurnCPU || e.Steps[1].Strategy != "" {
+		t.Fatalf("unexpected strategies %q and %q", e.Steps[0].Strategy, e.Steps[1].Strategy)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	var tests = []struct {
+		input string
+		err   string
+	}{
+		{`name: Empty`, "experiment has no steps"},
+		{`steps: [{strategy: ShutdownInstance}]`, "step 1: group must be specified"},
+		{`steps: [{group: g, strategy: Shutdown}]`, `step 1: unknown chaos strategy "Shutdown" (did you mean ShutdownInstance?)`},
+		{`steps: [{group: g}, {group: g, probability: 2}]`, "step 2: probability 2.000000 not between 0 and 1"},
+		{`steps: [{group: g, count: -1}]`, "step 1: invalid count -1"},
+		{`steps: [{group: g, duration: -5m}]`, "step 1: invalid duration -5m0s"},
+		{`steps: [{group: g, unknown: 1}]`, "field unknown not found"},
+	}
+
+	for _, tt := range tests {
+		_, err := experiment.Parse([]byte(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: expected error %q, got %v", tt.input, tt.err, err)
+		}
+	}
+}
+
+func TestRunner(t *testing.T) {
+	s := chaosmonkeytest.NewServer(&chaosmonkeytest.Config{
+		Groups: []string{"SomeAutoScalingGroup", "AnotherAutoScalingGroup"},
+	})
+	defer s.Close()
+
+	e, err := experiment.Parse([]byte(exampleYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Skip every second event
+	draws := []float64{0.1, 0.9, 0.2, 0.3}
+	var events []chaosmonkey.Event
+	var log bytes.Buffer
+	runner := &experiment.Runner{
+		Config:  chaosmonkey.Config{Endpoint: s.URL},
+		Log:     &log,
+		OnEvent: func(e chaosmonkey.Event) { events = append(events, e) },
+		Random: func() float64 {
+			f := draws[0]
+			draws = draws[1:]
+			return f
+		},
+	}
+
+	summary, err := runner.Run(context.Background(), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Triggered() != 3 || summary.Skipped() != 1 {
+		t.Fatalf("unexpected summary: %d triggered, %d skipped", summary.Triggered(), summary.Skipped())
+	}
+	if diff := cmp.Diff(s.Events(), events); diff != "" {
+		t.Fatal(diff)
+	}
+	if events[2].Region != "eu-west-1" || events[2].Strategy != chaosmonkey.StrategyBurnCPU {
+		t.Fatalf("unexpected event %+v", events[2])
+	}
+	if !strings.Contains(log.String(), "Summary: triggered 3 chaos event(s), skipped 1 chaos event(s)") {
+		t.Fatalf("unexpected log:\n%s", log.String())
+	}
+}
+
+func TestRunnerError(t *testing.T) {
+	s := chaosmonkeytest.NewServer(&chaosmonkeytest.Config{
+		Groups: []string{"SomeAutoScalingGroup"},
+	})
+	defer s.Close()
+
+	e, err := experiment.Parse([]byte(exampleYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &experiment.Runner{
+		Config: chaosmonkey.Config{Endpoint: s.URL},
+		Random: func() float64 { return 0 },
+	}
+	summary, err := runner.Run(context.Background(), e)
+	if !errors.Is(err, chaosmonkey.ErrGroupNotFound) {
+		t.Fatalf("expected ErrGroupNotFound, got %v", err)
+	}
+	if len(summary.Steps) != 2 || summary.Triggered() != 3 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+}
+
+type fakeAutoScaling map[string]aws.AutoScalingGroup
+
+func (f fakeAutoScaling) AutoScalingGroup(name string) (*aws.AutoScalingGroup, error) {
+	g, ok := f[name]
+	if !ok {
+		return nil, fmt.Errorf("auto scaling group %q does not exist", name)
+	}
+	return &g, nil
+}
+
+func (f fakeAutoScaling) WaitForGroupHealthy(ctx context.Context, name string, timeout time.Duration) error {
+	g, ok := f[name]
+	if !ok || g.InstancesInService < g.DesiredCapacity {
+		return fmt.Errorf("auto scaling group %q did not recover within %s", name, timeout)
+	}
+	return nil
+}
+
+func (f fakeAutoScaling) WaitForInstancesReplaced(ctx context.Context, name string, terminated []string, timeout time.Duration) error {
+	return f.WaitForGroupHealthy(ctx, name, timeout)
+}
+
+func TestRunnerDryRun(t *testing.T) {
+	s := chaosmonkeytest.NewServer(nil)
+	defer s.Close()
+
+	e, err := experiment.Parse([]byte(exampleYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := fakeAutoScaling{
+		"SomeAutoScalingGroup":    {Name: "SomeAutoScalingGroup", InstancesInService: 3, DesiredCapacity: 3, MaxSize: 5},
+		"AnotherAutoScalingGroup": {Name: "AnotherAutoScalingGroup", InstancesInService: 2, DesiredCapacity: 2, MaxSize: 5},
+	}
+	var regions []string
+	var log bytes.Buffer
+	runner := &experiment.Runner{
+		Config: chaosmonkey.Config{Endpoint: s.URL, Region: "us-east-1"},
+		Log:    &log,
+		Random: func() float64 { return 0.4 },
+		AutoScaling: func(region string) experiment.AutoScaling {
+			regions = append(regions, region)
+			return groups
+		},
+		DryRun: true,
+	}
+
+	summary, err := runner.Run(context.Background(), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Requests() != 0 {
+		t.Fatalf("expected no requests in dry run mode, got %d", s.Requests())
+	}
+	if summary.Triggered() != 0 || summary.DryRun() != 4 {
+		t.Fatalf("unexpected summary: %d triggered, %d dry run", summary.Triggered(), summary.DryRun())
+	}
+	expected := chaosmonkey.APIRequest{
+		ChaosType: "BurnCpu",
+		EventType: "CHAOS_TERMINATION",
+		GroupName: "AnotherAutoScalingGroup",
+		GroupType: "ASG",
+		Region:    "eu-west-1",
+	}
+	if diff := cmp.Diff(expected, summary.Steps[1].Requests[0]); diff != "" {
+		t.Fatal(diff)
+	}
+	if diff := cmp.Diff([]string{"us-east-1", "eu-west-1"}, regions); diff != "" {
+		t.Fatal(diff)
+	}
+	if !strings.Contains(log.String(), `"groupName": "AnotherAutoScalingGroup"`) {
+		t.Fatalf("request missing from log:\n%s", log.String())
+	}
+
+	delete(groups, "AnotherAutoScalingGroup")
+	if _, err := runner.Run(context.Background(), e); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected error for unknown group, got %v", err)
+	}
+}
+
+func TestParseFloor(t *testing.T) {
+	var tests = []struct {
+		input   string
+		desired int
+		min     int
+	}{
+		{"0", 10, 0},
+		{"2", 10, 2},
+		{"50%", 10, 5},
+		{"50%", 3, 2},
+		{"12.5%", 8, 1},
+	}
+
+	for _, tt := range tests {
+		f, err := experiment.ParseFloor(tt.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if min := f.Min(tt.desired); min != tt.min {
+			t.Errorf("%s of %d: expected %d, got %d", tt.input, tt.desired, tt.min, min)
+		}
+		if f.String() != tt.input {
+			t.Errorf("expected %q, got %q", tt.input, f.String())
+		}
+	}
+
+	for _, input := range []string{"", "-1", "abc", "101%", "x%"} {
+		if _, err := experiment.ParseFloor(input); err == nil {
+			t.Errorf("%q: expected error", input)
+		}
+	}
+}
+
+// shrinkingGroup is an auto scaling group that loses an instance every time
+// it is looked up, unless it is recovering.
+type shrinkingGroup struct {
+	group      aws.AutoScalingGroup
+	recovering bool
+}
+
+func (s *shrinkingGroup) AutoScalingGroup(name string) (*aws.AutoScalingGroup, error) {
+	g := s.group
+	if s.recovering {
+		s.group.InstancesInService++
+	} else {
+		s.group.InstancesInService--
+	}
+	return &g, nil
+}
+
+func (s *shrinkingGroup) WaitForGroupHealthy(ctx context.Context, name string, timeout time.Duration) error {
+	return nil
+}
+
+func (s *shrinkingGroup) WaitForInstancesReplaced(ctx context.Context, name string, terminated []string, timeout time.Duration) error {
+	return nil
+}
+
+func TestRunnerCapacityFloor(t *testing.T) {
+	s := chaosmonkeytest.NewServer(nil)
+	defer s.Close()
+
+	e, err := experiment.Parse([]byte(`
+steps:
+  - group: SomeAutoScalingGroup
+    count: 3
+    min-in-service: 50%
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	group := &shrinkingGroup{group: aws.AutoScalingGroup{
+		Name:               "SomeAutoScalingGroup",
+		InstancesInService: 4,
+		DesiredCapacity:    4,
+	}}
+	runner := &experiment.Runner{
+		Config:      chaosmonkey.Config{Endpoint: s.URL},
+		AutoScaling: func(string) experiment.AutoScaling { return group },
+	}
+
+	// 4 -> 3 -> 2, but not below 2
+	summary, err := runner.Run(context.Background(), e)
+	if !errors.Is(err, experiment.ErrCapacityFloor) {
+		t.Fatalf("expected ErrCapacityFloor, got %v", err)
+	}
+	if summary.Triggered() != 2 {
+		t.Fatalf("expected 2 triggered events, got %d", summary.Triggered())
+	}
+
+	// Wait for the group to recover
+	group.group.InstancesInService = 2
+	group.recovering = true
+	runner.CapacityWait = time.Second
+	runner.PollInterval = time.Millisecond
+	summary, err = runner.Run(context.Background(), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Triggered() != 3 {
+		t.Fatalf("expected 3 triggered events, got %d", summary.Triggered())
+	}
+
+	// Take planned events into account in dry run mode
+	runner.AutoScaling = func(string) experiment.AutoScaling {
+		return fakeAutoScaling{"SomeAutoScalingGroup": {InstancesInService: 4, DesiredCapacity: 4}}
+	}
+	runner.MinInService = experiment.Floor{Count: 2}
+	runner.DryRun = true
+	e.Steps[0].MinInService = experiment.Floor{}
+	summary, err = runner.Run(context.Background(), e)
+	if !errors.Is(err, experiment.ErrCapacityFloor) || summary.DryRun() != 2 {
+		t.Fatalf("expected ErrCapacityFloor after 2 events, got %v after %d", err, summary.DryRun())
+	}
+}
+
+// staleGroup is an auto scaling group that still lists the instances affected
+// by chaos events as in service, along with others to fill its capacity.
+type staleGroup struct {
+	server *chaosmonkeytest.Server
+}
+
+func (s *staleGroup) AutoScalingGroup(name string) (*aws.AutoScalingGroup, error) {
+	g := &aws.AutoScalingGroup{Name: name, InstancesInService: 4, DesiredCapacity: 4}
+	for _, e := range s.server.Events() {
+		g.InstanceIDs = append(g.InstanceIDs, e.InstanceID)
+	}
+	for len(g.InstanceIDs) < g.InstancesInService {
+		g.InstanceIDs = append(g.InstanceIDs, fmt.Sprintf("i-other%d", len(g.InstanceIDs)))
+	}
+	return g, nil
+}
+
+func (s *staleGroup) WaitForGroupHealthy(ctx context.Context, name string, timeout time.Duration) error {
+	return nil
+}
+
+func (s *staleGroup) WaitForInstancesReplaced(ctx context.Context, name string, terminated []string, timeout time.Duration) error {
+	return nil
+}
+
+func TestRunnerCapacityFloorStale(t *testing.T) {
+	s := chaosmonkeytest.NewServer(nil)
+	defer s.Close()
+
+	e, err := experiment.Parse([]byte(`steps: [{group: SomeAutoScalingGroup, count: 3, min-in-service: 50%}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := &experiment.Runner{
+		Config:      chaosmonkey.Config{Endpoint: s.URL},
+		AutoScaling: func(string) experiment.AutoScaling { return &staleGroup{s} },
+	}
+
+	// Instances just terminated must not count as in service
+	summary, err := runner.Run(context.Background(), e)
+	if !errors.Is(err, experiment.ErrCapacityFloor) {
+		t.Fatalf("expected ErrCapacityFloor, got %v", err)
+	}
+	if summary.Triggered() != 2 {
+		t.Fatalf("expected 2 triggered events, got %d", summary.Triggered())
+	}
+}
+
+func TestRunnerRecovery(t *testing.T) {
+	s := chaosmonkeytest.NewServer(nil)
+	defer s.Close()
+
+	e, err := experiment.Parse([]byte(`steps: [{group: SomeAutoScalingGroup, count: 3}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := fakeAutoScaling{"SomeAutoScalingGroup": {InstancesInService: 3, DesiredCapacity: 3}}
+	runner := &experiment.Runner{
+		Config:          chaosmonkey.Config{Endpoint: s.URL},
+		AutoScaling:     func(string) experiment.AutoScaling { return groups },
+		RecoveryTimeout: time.Minute,
+	}
+	summary, err := runner.Run(context.Background(), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Triggered() != 3 {
+		t.Fatalf("expected 3 triggered events, got %d", summary.Triggered())
+	}
+
+	groups["SomeAutoScalingGroup"] = aws.AutoScalingGroup{InstancesInService: 2, DesiredCapacity: 3}
+	summary, err = runner.Run(context.Background(), e)
+	if err == nil || !strings.Contains(err.Error(), "did not recover") {
+		t.Fatalf("expected recovery error, got %v", err)
+	}
+	if summary.Triggered() != 1 {
+		t.Fatalf("expected 1 triggered event, got %d", summary.Triggered())
+	}
+}
+
+// recoveringGroup records how the runner waits for recovery.
+type recoveringGroup struct {
+	healthy  int
+	replaced [][]string
+}
+
+func (g *recoveringGroup) AutoScalingGroup(name string) (*aws.AutoScalingGroup, error) {
+	return &aws.AutoScalingGroup{Name: name, InstancesInService: 3, DesiredCapacity: 3}, nil
+}
+
+func (g *recoveringGroup) WaitForGroupHealthy(ctx context.Context, name string, timeout time.Duration) error {
+	g.healthy++
+	return nil
+}
+
+func (g *recoveringGroup) WaitForInstancesReplaced(ctx context.Context, name string, terminated []string, timeout time.Duration) error {
+	g.replaced = append(g.replaced, terminated)
+	return nil
+}
+
+func TestRunnerRecoveryStrategy(t *testing.T) {
+	s := chaosmonkeytest.NewServer(nil)
+	defer s.Close()
+
+	e, err := experiment.Parse([]byte(`
+steps:
+  - {group: SomeAutoScalingGroup, strategy: BurnCpu, count: 2}
+  - {group: SomeAutoScalingGroup, count: 2}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := &recoveringGroup{}
+	runner := &experiment.Runner{
+		Config:          chaosmonkey.Config{Endpoint: s.URL},
+		AutoScaling:     func(string) experiment.AutoScaling { return group },
+		RecoveryTimeout: time.Minute,
+	}
+	if _, err := runner.Run(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+
+	// Instances hit by BurnCpu stay in service and need no replacement
+	if group.healthy != 1 {
+		t.Errorf("want 1 wait for a healthy group, got %d", group.healthy)
+	}
+	if len(group.replaced) != 1 || len(group.replaced[0]) != 1 {
+		t.Fatalf("want 1 wait for the replacement of 1 instance, got %q", group.replaced)
+	}
+	for _, e := range s.Events() {
+		if e.InstanceID == group.replaced[0][0] && e.Strategy != chaosmonkey.StrategyShutdownInstance {
+			t.Errorf("instance %s hit by %s must not be replaced", e.InstanceID, e.Strategy)
+		}
+	}
+}
+
+func TestProbe(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "status: OK")
+	}))
+	defer ts.Close()
+
+	var tests = []struct {
+		probe string
+		ok    bool
+	}{
+		{"http=" + ts.URL, true},
+		{"http=" + ts.URL + ";expect-body=status: (OK|FINE)", true},
+		{"http=" + ts.URL + ";expect-body=FAIL", false},
+		{"http=" + ts.URL + ";expect-status=204", false},
+		{"tcp=" + ts.Listener.Addr().String(), true},
+		{"command=true;name=success", true},
+		{"command=exit 1", false},
+		{"command=sleep 1;timeout=10ms", false},
+	}
+
+	for _, tt := range tests {
+		p, err := experiment.ParseProbe(tt.probe)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Run(context.Background()); (err == nil) != tt.ok {
+			t.Errorf("%q: expected success %v, got error %v", tt.probe, tt.ok, err)
+		}
+	}
+
+	for _, s := range []string{"", "http", "foo=bar", "http=x;tcp=y", "http=x;expect-status=abc", "http=x;expect-body=("} {
+		if _, err := experiment.ParseProbe(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestRunnerProbes(t *testing.T) {
+	s := chaosmonkeytest.NewServer(nil)
+	defer s.Close()
+
+	healthy := true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	e, err := experiment.Parse([]byte(`
+probes:
+  - name: health
+    http: ` + ts.URL + `
+steps:
+  - group: SomeAutoScalingGroup
+    count: 2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &experiment.Runner{
+		Config: chaosmonkey.Config{Endpoint: s.URL},
+		Probes: []experiment.Probe{{Command: "true"}},
+		OnEvent: func(chaosmonkey.Event) {
+			healthy = false
+		},
+	}
+
+	summary, err := runner.Run(context.Background(), e)
+	if !errors.Is(err, experiment.ErrHypothesisFailed) {
+		t.Fatalf("expected ErrHypothesisFailed, got %v", err)
+	}
+	if summary.Triggered() != 1 {
+		t.Fatalf("expected 1 triggered event, got %d", summary.Triggered())
+	}
+	var probes []string
+	for _, p := range summary.Probes {
+		probes = append(probes, fmt.Sprintf("%s %s %v", p.Probe, p.After, p.Err == nil))
+	}
+	instanceID := summary.Steps[0].Events[0].InstanceID
+	expected := []string{
+		`"true"  true`,
+		"health  true",
+		`"true" ` + instanceID + " true",
+		"health " + instanceID + " false",
+	}
+	if diff := cmp.Diff(expected, probes); diff != "" {
+		t.Fatal(diff)
+	}
+
+	summary, err = runner.Run(context.Background(), e)
+	if !errors.Is(err, experiment.ErrHypothesisFailed) || summary.Triggered() != 0 {
+		t.Fatalf("expected ErrHypothesisFailed before first event, got %v", err)
+	}
+}
+
+func TestReport(t *testing.T) {
+	s := chaosmonkeytest.NewServer(nil)
+	defer s.Close()
+
+	e, err := experiment.Parse([]byte(`
+name: Report <test>
+probes:
+  - command: "true"
+steps:
+  - group: SomeAutoScalingGroup
+    count: 2
+    note: Some note
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := fakeAutoScaling{"SomeAutoScalingGroup": {Name: "SomeAutoScalingGroup", InstancesInService: 3, DesiredCapacity: 3, MaxSize: 5}}
+	runner := &experiment.Runner{
+		Config:          chaosmonkey.Config{Endpoint: s.URL},
+		AutoScaling:     func(string) experiment.AutoScaling { return groups },
+		CaptureCapacity: true,
+	}
+	summary, err := runner.Run(context.Background(), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rep := runner.NewReport(e, summary, errors.New("oops"))
+	if rep.Triggered != 2 || len(rep.Steps) != 1 || rep.Steps[0].CapacityBefore.MaxSize != 5 ||
+		len(rep.Probes) != 3 || rep.Error != "oops" {
+		t.Fatalf("unexpected report %+v", rep)
+	}
+
+	dir := t.TempDir()
+	paths, err := rep.Write(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 3 {
+		t.Fatalf("expected 3 files, got %v", paths)
+	}
+
+	var decoded experiment.Report
+	data, err := ioutil.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(rep.Steps[0].Events, decoded.Steps[0].Events); diff != "" {
+		t.Fatal(diff)
+	}
+
+	instanceID := summary.Steps[0].Events[1].InstanceID
+	for _, path := range paths[1:] {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range []string{instanceID, "Some note", "oops"} {
+			if !strings.Contains(string(data), s) {
+				t.Errorf("%s: %q not found", path, s)
+			}
+		}
+	}
+	if data, _ := ioutil.ReadFile(paths[2]); !strings.Contains(string(data), "Report &lt;test&gt;") {
+		t.Error("HTML report is not escaped")
+	}
+}
+
+func TestRunnerNotifications(t *testing.T) {
+	s := chaosmonkeytest.NewServer(&chaosmonkeytest.Config{
+		Groups: []string{"SomeAutoScalingGroup"},
+	})
+	defer s.Close()
+
+	e, err := experiment.Parse([]byte(exampleYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu       sync.Mutex
+		payloads []experiment.Notification
+		failures = 1
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if got, want := r.Header.Get("Authorization"), "Bearer token"; got != want {
+			t.Errorf("want header %q, got %q", want, got)
+		}
+		if got, want := r.Header.Get(experiment.SignatureHeader), "sha256="+experiment.Sign(body, "secret"); got != want {
+			t.Errorf("want signature %q, got %q", want, got)
+		}
+		var n experiment.Notification
+		if err := json.Unmarshal(body, &n); err != nil {
+			t.Error(err)
+		}
+		payloads = append(payloads, n)
+	}))
+	defer hook.Close()
+
+	var messages []string
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct{ Text string }
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		messages = append(messages, msg.Text)
+		mu.Unlock()
+	}))
+	defer slack.Close()
+
+	draws := []float64{0.1, 0.9, 0.2, 0.3}
+	runner := &experiment.Runner{
+		Config: chaosmonkey.Config{Endpoint: s.URL},
+		Random: func() float64 {
+			f := draws[0]
+			draws = draws[1:]
+			return f
+		},
+		Notifiers: []experiment.Notifier{
+			&experiment.Webhook{
+				URL:        hook.URL,
+				Headers:    map[string]string{"Authorization": "Bearer token"},
+				Secret:     "secret",
+				Retries:    1,
+				RetryDelay: time.Millisecond,
+			},
+			&experiment.Webhook{URL: slack.URL, Format: experiment.WebhookFormatSlack},
+		},
+	}
+	if _, err := runner.Run(context.Background(), e); !errors.Is(err, chaosmonkey.ErrGroupNotFound) {
+		t.Fatalf("expected ErrGroupNotFound, got %v", err)
+	}
+
+	var types []experiment.NotificationType
+	for _, n := range payloads {
+		if n.Experiment != "Example" {
+			t.Errorf("unexpected experiment name %q", n.Experiment)
+		}
+		types = append(types, n.Type)
+	}
+	want := []experiment.NotificationType{
+		experiment.RunStarted,
+		experiment.EventTriggered,
+		experiment.EventSkipped,
+		experiment.EventTriggered,
+		experiment.RunFailed,
+	}
+	if diff := cmp.Diff(want, types); diff != "" {
+		t.Fatal(diff)
+	}
+	if id := payloads[1].Event.InstanceID; id != s.Events()[0].InstanceID {
+		t.Errorf("unexpected instance %q in notification", id)
+	}
+	if payloads[4].Triggered != 2 || payloads[4].Skipped != 1 || payloads[4].Error == "" {
+		t.Errorf("unexpected final notification %+v", payloads[4])
+	}
+
+	if len(messages) != len(want) {
+		t.Fatalf("want %d Slack messages, got %d", len(want), len(messages))
+	}
+	if want := `Chaos Monkey experiment "Example" started`; messages[0] != want {
+		t.Errorf("want Slack message %q, got %q", want, messages[0])
+	}
+	if !strings.Contains(messages[4], "failed after triggering 2 chaos event(s)") {
+		t.Errorf("unexpected Slack message %q", messages[4])
+	}
+}
+
+// hangingNotifier records notifications, but only returns once the context is
+// done.
+type hangingNotifier struct {
+	types []experiment.NotificationType
+}
+
+func (h *hangingNotifier) Notify(ctx context.Context, n *experiment.Notification) error {
+	<-ctx.Done()
+	h.types = append(h.types, n.Type)
+	return ctx.Err()
+}
+
+func TestRunnerNotifyTimeout(t *testing.T) {
+	e := &experiment.Experiment{Steps: []experiment.Step{{
+		Group:    "web",
+		Strategy: chaosmonkey.StrategyShutdownInstance,
+		Count:    2,
+		Interval: time.Millisecond,
+	}}}
+	notifier := &hangingNotifier{}
+	runner := &experiment.Runner{
+		Backend: func(region string) chaosmonkey.Backend {
+			return &fakeBackend{instances: map[string][]string{"web": {"i-1", "i-2"}}}
+		},
+		Notifiers:     []experiment.Notifier{notifier},
+		NotifyTimeout: 10 * time.Millisecond,
+	}
+
+	start := time.Now()
+	if _, err := runner.Run(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+	// Notifications are still sent after the run was interrupted
+	ctx, cancel := context.WithCancel(context.Background())
+	runner.OnEvent = func(chaosmonkey.Event) { cancel() }
+	if _, err := runner.Run(ctx, e); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("notifications blocked the runs for %s", elapsed)
+	}
+
+	want := []experiment.NotificationType{
+		experiment.RunStarted,
+		experiment.EventTriggered,
+		experiment.EventTriggered,
+		experiment.RunFinished,
+		experiment.RunStarted,
+		experiment.EventTriggered,
+		experiment.RunFailed,
+	}
+	if diff := cmp.Diff(want, notifier.types); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+// fakeBackend terminates instances of the groups without Chaos Monkey.
+type fakeBackend struct {
+	region    string
+	instances map[string][]string
+}
+
+func (b *fakeBackend) TriggerEventContext(ctx context.Context, group string, strategy chaosmonkey.Strategy) (*chaosmonkey.Event, error) {
+	if strategy != chaosmonkey.StrategyShutdownInstance {
+		return nil, fmt.Errorf("%w: %s", chaosmonkey.ErrUnsupportedStrategy, strategy)
+	}
+	instances := b.instances[group]
+	if len(instances) == 0 {
+		return nil, chaosmonkey.ErrNoInstance
+	}
+	b.instances[group] = instances[1:]
+	return &chaosmonkey.Event{
+		InstanceID:           instances[0],
+		AutoScalingGroupName: group,
+		Region:               b.region,
+		Strategy:             strategy,
+	}, nil
+}
+
+func TestRunnerBackend(t *testing.T) {
+	e, err := experiment.Parse([]byte(exampleYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var regions []string
+	backend := &fakeBackend{instances: map[string][]string{
+		"SomeAutoScalingGroup": {"i-1", "i-2", "i-3"},
+	}}
+	var events []chaosmonkey.Event
+	runner := &experiment.Runner{
+		Config: chaosmonkey.Config{Endpoint: "http://chaosmonkey.invalid", Region: "us-east-1"},
+		Backend: func(region string) chaosmonkey.Backend {
+			regions = append(regions, region)
+			backend.region = region
+			return backend
+		},
+		OnEvent: func(e chaosmonkey.Event) { events = append(events, e) },
+		Random:  func() float64 { return 0 },
+	}
+
+	summary, err := runner.Run(context.Background(), e)
+	if !errors.Is(err, chaosmonkey.ErrUnsupportedStrategy) {
+		t.Fatalf("expected ErrUnsupportedStrategy, got %v", err)
+	}
+	if summary.Triggered() != 3 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	if diff := cmp.Diff([]string{"us-east-1", "eu-west-1"}, regions); diff != "" {
+		t.Fatal(diff)
+	}
+	var ids []string
+	for _, e := range events {
+		ids = append(ids, e.InstanceID)
+	}
+	if diff := cmp.Diff([]string{"i-1", "i-2", "i-3"}, ids); diff != "" {
+		t.Fatal(diff)
+	}
+
+	var log bytes.Buffer
+	runner.DryRun, runner.Log = true, &log
+	if _, err := runner.Run(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(log.String(), "POST") {
+		t.Fatalf("unexpected API request in log:\n%s", log.String())
+	}
+
+	// Durations require a backend supporting them
+	e.Steps[0].Duration = time.Minute
+	runner.DryRun = false
+	if _, err := runner.Run(context.Background(), e); !errors.Is(err, chaosmonkey.ErrUnsupportedDuration) {
+		t.Fatalf("expected ErrUnsupportedDuration, got %v", err)
+	}
+}
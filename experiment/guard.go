@@ -0,0 +1,145 @@
+package experiment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrCapacityFloor is returned if a chaos event would drop the number of
+// instances in service of an auto scaling group below its floor.
+var ErrCapacityFloor = errors.New("capacity floor reached")
+
+// Floor is the minimum number of instances in service an auto scaling group
+// must keep during an experiment, either as absolute number or as percentage
+// of its desired capacity. The zero value means no floor.
+type Floor struct {
+	Count   int
+	Percent float64
+}
+
+// ParseFloor parses a floor given as number, e.g. "2", or as percentage, e.g.
+// "50%".
+func ParseFloor(s string) (Floor, error) {
+	if strings.HasSuffix(s, "%") {
+		p, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil || p < 0 || p > 100 {
+			return Floor{}, fmt.Errorf("invalid percentage %q", s)
+		}
+		return Floor{Percent: p}, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return Floor{}, fmt.Errorf("invalid number of instances %q", s)
+	}
+	return Floor{Count: n}, nil
+}
+
+// IsZero reports whether f is the zero value, which means no floor.
+func (f Floor) IsZero() bool {
+	return f.Count == 0 && f.Percent == 0
+}
+
+// Min returns the minimum number of instances in service for an auto scaling
+// group with the given desired capacity.
+func (f Floor) Min(desired int) int {
+	if f.Percent > 0 {
+		return int(math.Ceil(float64(desired) * f.Percent / 100))
+	}
+	return f.Count
+}
+
+// String returns the floor in the format understood by ParseFloor.
+func (f Floor) String() string {
+	if f.Percent > 0 {
+		return strconv.FormatFloat(f.Percent, 'f', -1, 64) + "%"
+	}
+	return strconv.Itoa(f.Count)
+}
+
+// Set implements flag.Value.
+func (f *Floor) Set(s string) error {
+	v, err := ParseFloor(s)
+	if err != nil {
+		return err
+	}
+	*f = v
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (f *Floor) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	return f.Set(s)
+}
+
+// checkCapacity returns nil if the auto scaling group of the step can afford
+// to lose another instance without dropping below its floor. If not, it waits
+// up to CapacityWait for the group to regain capacity. The instances affected
+// by the chaos events of the step so far are not counted as in service, even
+// if the group still lists them, nor are the events planned in dry run mode.
+func (r *Runner) checkCapacity(ctx context.Context, region string, step *Step, result *StepResult) error {
+	floor := step.MinInService
+	if floor.IsZero() {
+		floor = r.MinInService
+	}
+	if floor.IsZero() {
+		return nil
+	}
+	if r.AutoScaling == nil {
+		return errors.New("access to auto scaling groups is required to check capacity")
+	}
+
+	deadline := time.Now().Add(r.CapacityWait)
+	for {
+		g, err := r.AutoScaling(region).AutoScalingGroup(step.Group)
+		if err != nil {
+			return err
+		}
+		inService := g.InstancesInService - len(result.Requests)
+		for _, id := range g.InstanceIDs {
+			for _, e := range result.Events {
+				if e.InstanceID == id {
+					inService--
+				}
+			}
+		}
+		min := floor.Min(g.DesiredCapacity)
+		if inService-1 >= min {
+			return nil
+		}
+
+		err = fmt.Errorf("%w: group %s has %d instance(s) in service, another chaos event would drop it below %d",
+			ErrCapacityFloor, step.Group, inService, min)
+		if r.DryRun || !time.Now().Before(deadline) {
+			return err
+		}
+		r.logf("Pausing until capacity is regained: %s", err)
+		if err := r.poll(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// poll waits for the poll interval or until the context is done.
+func (r *Runner) poll(ctx context.Context) error {
+	d := r.PollInterval
+	if d <= 0 {
+		d = 10 * time.Second
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
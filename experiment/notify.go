@@ -0,0 +1,236 @@
+package experiment
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+)
+
+// NotificationType defines what a notification is about.
+type NotificationType string
+
+// These are the types of notifications sent during an experiment run.
+const (
+	RunStarted     NotificationType = "RunStarted"
+	EventTriggered NotificationType = "EventTriggered"
+	EventSkipped   NotificationType = "EventSkipped"
+	RunFinished    NotificationType = "RunFinished"
+	RunFailed      NotificationType = "RunFailed"
+)
+
+// Notification describes something that happened during an experiment run.
+// Fields that do not apply to the notification's type are empty.
+type Notification struct {
+	Type NotificationType
+
+	// Name of the experiment and whether it is a dry run
+	Experiment string
+	DryRun     bool
+
+	// Time when the notification was created
+	Time time.Time
+
+	// Number of the step, its auto scaling group, chaos strategy, and
+	// probability of chaos events
+	Step        int
+	Group       string
+	Strategy    chaosmonkey.Strategy
+	Probability float64
+
+	// Triggered chaos event
+	Event *chaosmonkey.Event
+
+	// Total number of triggered and skipped chaos events at the end of the
+	// run
+	Triggered int
+	Skipped   int
+
+	// Error that caused the run to fail
+	Error string
+}
+
+// Message returns a human-readable description of the notification.
+func (n *Notification) Message() string {
+	name := "Chaos Monkey run"
+	if n.Experiment != "" {
+		name = fmt.Sprintf("Chaos Monkey experiment %q", n.Experiment)
+	}
+	if n.DryRun {
+		name += " (dry run)"
+	}
+
+	switch n.Type {
+	case RunStarted:
+		return name + " started"
+	case EventTriggered:
+		return fmt.Sprintf("%s: triggered %s on instance %s in group %s",
+			name, n.Event.Strategy, n.Event.InstanceID, n.Group)
+	case EventSkipped:
+		return fmt.Sprintf("%s: skipped chaos event in group %s with probability of %g",
+			name, n.Group, n.Probability)
+	case RunFinished:
+		return fmt.Sprintf("%s finished: triggered %d chaos event(s), skipped %d chaos event(s)",
+			name, n.Triggered, n.Skipped)
+	case RunFailed:
+		return fmt.Sprintf("%s failed after triggering %d chaos event(s): %s",
+			name, n.Triggered, n.Error)
+	}
+	return fmt.Sprintf("%s: %s", name, n.Type)
+}
+
+// Notifier is informed about the lifecycle of an experiment run.
+type Notifier interface {
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// notify sends the notification to all notifiers, giving each of them up to
+// the notify timeout. Failed notifications are logged, but do not affect the
+// run.
+func (r *Runner) notify(ctx context.Context, e *Experiment, n Notification) {
+	n.Experiment = e.Name
+	n.DryRun = r.DryRun
+	n.Time = time.Now()
+
+	timeout := r.NotifyTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	// Always notify, even if the run was interrupted
+	if ctx.Err() != nil {
+		ctx = context.Background()
+	}
+	for _, notifier := range r.Notifiers {
+		nctx, cancel := context.WithTimeout(ctx, timeout)
+		err := notifier.Notify(nctx, &n)
+		cancel()
+		if err != nil {
+			r.logf("Failed to send notification: %s", err)
+		}
+	}
+}
+
+// Payload formats supported by Webhook
+const (
+	WebhookFormatJSON  = "json"
+	WebhookFormatSlack = "slack"
+)
+
+// Webhook is a Notifier that sends notifications as JSON via HTTP POST.
+type Webhook struct {
+	// URL to send notifications to
+	URL string
+
+	// Payload format, either WebhookFormatJSON (the default), which sends
+	// the Notification as is, or WebhookFormatSlack, which sends a message
+	// compatible with Slack's incoming webhooks
+	Format string
+
+	// Optional HTTP headers to add to each request
+	Headers map[string]string
+
+	// Optional secret used to sign the payload with HMAC-SHA256. The
+	// signature is sent hex-encoded in the X-Chaosmonkey-Signature header
+	// in the form "sha256=<signature>".
+	Secret string
+
+	// Number of times to retry failed requests. No more retries are made
+	// if the context's deadline would pass before the next one.
+	Retries int
+
+	// Delay before the first retry, doubled with every further retry (1
+	// second by default)
+	RetryDelay time.Duration
+
+	// Custom HTTP client to use (client with 10 seconds timeout by default)
+	HTTPClient *http.Client
+}
+
+// SignatureHeader is the HTTP header containing the payload signature sent by
+// Webhook.
+const SignatureHeader = "X-Chaosmonkey-Signature"
+
+// Notify implements Notifier.
+func (w *Webhook) Notify(ctx context.Context, n *Notification) error {
+	var payload interface{} = n
+	switch w.Format {
+	case "", WebhookFormatJSON:
+	case WebhookFormatSlack:
+		payload = map[string]string{"text": n.Message()}
+	default:
+		return fmt.Errorf("unknown webhook format %q", w.Format)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	delay := w.RetryDelay
+	if delay <= 0 {
+		delay = time.Second
+	}
+	for attempt := 0; ; attempt++ {
+		err = w.send(ctx, body)
+		if err == nil || attempt >= w.Retries {
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		delay *= 2
+	}
+}
+
+func (w *Webhook) send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	if w.Secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(body, w.Secret))
+	}
+
+	client := w.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned HTTP error: %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the hex-encoded HMAC-SHA256 signature of the payload, as sent
+// by Webhook.
+func Sign(payload []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,237 @@
+package experiment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrHypothesisFailed is returned if a probe fails, meaning that the system
+// is not in its steady state.
+var ErrHypothesisFailed = errors.New("steady-state hypothesis failed")
+
+// Probe describes a check of the steady state of the system under test. A
+// probe either sends an HTTP GET request, connects to a TCP address, or
+// executes a shell command.
+type Probe struct {
+	// Optional name of the probe
+	Name string `yaml:"name"`
+
+	// URL to send an HTTP GET request to
+	HTTP string `yaml:"http"`
+
+	// Address to connect to via TCP, e.g. "example.com:443"
+	TCP string `yaml:"tcp"`
+
+	// Shell command that must exit with status code 0
+	Command string `yaml:"command"`
+
+	// Expected HTTP status code (200 by default)
+	ExpectStatus int `yaml:"expect-status"`
+
+	// Optional regular expression the HTTP response body must match
+	ExpectBody string `yaml:"expect-body"`
+
+	// Time after which the probe fails (10 seconds by default)
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// ParseProbe parses a probe given as semicolon-separated list of key=value
+// pairs, using the same keys as experiment files, e.g.
+// "http=http://example.com/health;expect-status=200".
+func ParseProbe(s string) (*Probe, error) {
+	var p Probe
+	for _, kv := range strings.Split(s, ";") {
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid probe %q, expected key=value pairs", s)
+		}
+		k, v := strings.TrimSpace(kv[:i]), kv[i+1:]
+
+		var err error
+		switch k {
+		case "name":
+			p.Name = v
+		case "http":
+			p.HTTP = v
+		case "tcp":
+			p.TCP = v
+		case "command":
+			p.Command = v
+		case "expect-status":
+			p.ExpectStatus, err = strconv.Atoi(v)
+		case "expect-body":
+			p.ExpectBody = v
+		case "timeout":
+			p.Timeout, err = time.ParseDuration(v)
+		default:
+			err = fmt.Errorf("unknown key %q", k)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid probe %q: %s", s, err)
+		}
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Validate returns an error if the probe is invalid.
+func (p *Probe) Validate() error {
+	n := 0
+	for _, v := range []string{p.HTTP, p.TCP, p.Command} {
+		if v != "" {
+			n++
+		}
+	}
+	if n != 1 {
+		return fmt.Errorf("probe %s: exactly one of http, tcp, or command must be specified", p)
+	}
+	if p.ExpectBody != "" {
+		if _, err := regexp.Compile(p.ExpectBody); err != nil {
+			return fmt.Errorf("probe %s: %s", p, err)
+		}
+	}
+	if p.Timeout < 0 {
+		return fmt.Errorf("probe %s: invalid timeout %s", p, p.Timeout)
+	}
+	return nil
+}
+
+// String returns the name of the probe, or a description if it has no name.
+func (p *Probe) String() string {
+	switch {
+	case p.Name != "":
+		return p.Name
+	case p.HTTP != "":
+		return "GET " + p.HTTP
+	case p.TCP != "":
+		return "TCP " + p.TCP
+	case p.Command != "":
+		return strconv.Quote(p.Command)
+	}
+	return "<empty>"
+}
+
+// Run executes the probe and returns an error if it failed.
+func (p *Probe) Run(ctx context.Context) error {
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch {
+	case p.HTTP != "":
+		return p.runHTTP(ctx)
+	case p.TCP != "":
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", p.TCP)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case p.Command != "":
+		out, err := exec.CommandContext(ctx, "sh", "-c", p.Command).CombinedOutput()
+		if err != nil {
+			if out := strings.TrimSpace(string(out)); out != "" {
+				return fmt.Errorf("%s: %s", err, out)
+			}
+			return err
+		}
+		return nil
+	}
+	return p.Validate()
+}
+
+func (p *Probe) runHTTP(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.HTTP, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	expected := p.ExpectStatus
+	if expected == 0 {
+		expected = http.StatusOK
+	}
+	if resp.StatusCode != expected {
+		return fmt.Errorf("expected HTTP status %d, got %s", expected, resp.Status)
+	}
+
+	if p.ExpectBody != "" {
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		re, err := regexp.Compile(p.ExpectBody)
+		if err != nil {
+			return err
+		}
+		if !re.Match(body) {
+			return fmt.Errorf("response body does not match %q", p.ExpectBody)
+		}
+	}
+	return nil
+}
+
+// ProbeResult describes the outcome of running a probe.
+type ProbeResult struct {
+	// Name or description of the probe
+	Probe string
+
+	// Instance ID of the chaos event after which the probe was run, or
+	// empty if the probe was run to establish the steady state
+	After string
+
+	// Time when the probe was run and how long it took
+	Time     time.Time
+	Duration time.Duration
+
+	// Error if the probe failed
+	Err error
+}
+
+// runProbes runs all probes of the runner and the experiment, records their
+// results, and returns an error if one of them failed. after is the instance
+// ID of the preceding chaos event, if any.
+func (r *Runner) runProbes(ctx context.Context, e *Experiment, summary *Summary, after string) error {
+	probes := append(append([]Probe{}, r.Probes...), e.Probes...)
+	for i := range probes {
+		p := &probes[i]
+		start := time.Now()
+		err := p.Run(ctx)
+		summary.Probes = append(summary.Probes, ProbeResult{
+			Probe:    p.String(),
+			After:    after,
+			Time:     start,
+			Duration: time.Since(start),
+			Err:      err,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if after == "" {
+				return fmt.Errorf("%w before first chaos event: probe %s: %s", ErrHypothesisFailed, p, err)
+			}
+			return fmt.Errorf("%w after chaos event on instance %s: probe %s: %s", ErrHypothesisFailed, after, p, err)
+		}
+		r.logf("Probe %s succeeded", p)
+	}
+	return nil
+}
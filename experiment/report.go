@@ -0,0 +1,328 @@
+package experiment
+
+import (
+	"encoding/json"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/mlafeldt/chaosmonkey/aws"
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+)
+
+// Report describes an experiment run in a form suitable for documentation,
+// e.g. to attach it to a postmortem.
+type Report struct {
+	// Name and description of the experiment
+	Name        string
+	Description string
+
+	// Settings of the run
+	Endpoint        string
+	Region          string
+	DryRun          bool
+	MinInService    string
+	CapacityWait    string
+	RecoveryTimeout string
+
+	// Time when the run started and finished, and how long it took
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Duration   string
+
+	// Total number of triggered and skipped chaos events
+	Triggered int
+	Skipped   int
+
+	// Results of the executed steps
+	Steps []ReportStep
+
+	// Results of all probes in the order they were run
+	Probes []ReportProbe
+
+	// Error that caused the run to fail, if any
+	Error string
+}
+
+// ReportStep describes the result of a single step in a report.
+type ReportStep struct {
+	Number       int
+	Group        string
+	Strategy     chaosmonkey.Strategy
+	Region       string
+	Count        int
+	Interval     string
+	Probability  float64
+	Duration     string
+	MinInService string
+	Note         string
+
+	// Triggered chaos events and the number of skipped ones
+	Events  []chaosmonkey.Event
+	Skipped int
+
+	// Requests that would have been sent in dry run mode
+	Requests []chaosmonkey.APIRequest
+
+	// Capacity of the auto scaling group before and after the step, if
+	// recorded
+	CapacityBefore *aws.AutoScalingGroup
+	CapacityAfter  *aws.AutoScalingGroup
+}
+
+// ReportProbe describes the outcome of running a probe in a report.
+type ReportProbe struct {
+	Probe    string
+	After    string
+	Time     time.Time
+	Duration string
+	Error    string
+}
+
+// NewReport returns a report for the run of the experiment with the given
+// summary and error.
+func (r *Runner) NewReport(e *Experiment, s *Summary, err error) *Report {
+	rep := &Report{
+		Name:        e.Name,
+		Description: e.Description,
+		Endpoint:    r.Config.Endpoint,
+		Region:      r.Config.Region,
+		DryRun:      r.DryRun,
+		StartedAt:   s.StartedAt,
+		FinishedAt:  s.FinishedAt,
+		Duration:    s.FinishedAt.Sub(s.StartedAt).Round(time.Millisecond).String(),
+		Triggered:   s.Triggered(),
+		Skipped:     s.Skipped(),
+	}
+	if !r.MinInService.IsZero() {
+		rep.MinInService = r.MinInService.String()
+	}
+	if r.CapacityWait > 0 {
+		rep.CapacityWait = r.CapacityWait.String()
+	}
+	if r.RecoveryTimeout > 0 {
+		rep.RecoveryTimeout = r.RecoveryTimeout.String()
+	}
+	if err != nil {
+		rep.Error = err.Error()
+	}
+
+	for i, res := range s.Steps {
+		step := ReportStep{
+			Number:         i + 1,
+			Group:          res.Step.Group,
+			Strategy:       res.Step.Strategy,
+			Region:         res.Region,
+			Count:          res.Step.count(),
+			Interval:       res.Step.Interval.String(),
+			Probability:    res.Step.probability(),
+			Note:           res.Step.Note,
+			Events:         res.Events,
+			Skipped:        res.Skipped,
+			Requests:       res.Requests,
+			CapacityBefore: res.CapacityBefore,
+			CapacityAfter:  res.CapacityAfter,
+		}
+		if res.Step.Duration > 0 {
+			step.Duration = res.Step.Duration.String()
+		}
+		if !res.Step.MinInService.IsZero() {
+			step.MinInService = res.Step.MinInService.String()
+		}
+		rep.Steps = append(rep.Steps, step)
+	}
+
+	for _, p := range s.Probes {
+		probe := ReportProbe{
+			Probe:    p.Probe,
+			After:    p.After,
+			Time:     p.Time,
+			Duration: p.Duration.Round(time.Millisecond).String(),
+		}
+		if p.Err != nil {
+			probe.Error = p.Err.Error()
+		}
+		rep.Probes = append(rep.Probes, probe)
+	}
+
+	return rep
+}
+
+// Write writes the report as JSON, Markdown, and HTML file to the given
+// directory, which is created if it does not exist. The file names are derived
+// from the start time of the run. It returns the paths of the written files.
+func (rep *Report) Write(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	base := filepath.Join(dir, "chaosmonkey-"+rep.StartedAt.UTC().Format("20060102T150405Z"))
+
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	var md, html strings.Builder
+	if err := markdownTemplate.Execute(&md, rep); err != nil {
+		return nil, err
+	}
+	if err := htmlTemplate.Execute(&html, rep); err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, f := range []struct {
+		ext     string
+		content []byte
+	}{
+		{".json", append(data, '\n')},
+		{".md", []byte(md.String())},
+		{".html", []byte(html.String())},
+	} {
+		path := base + f.ext
+		if err := ioutil.WriteFile(path, f.content, 0644); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+var reportFuncs = map[string]interface{}{
+	"time": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.UTC().Format(time.RFC3339)
+	},
+}
+
+var markdownTemplate = template.Must(template.New("markdown").Funcs(reportFuncs).Parse(
+	`# Chaos Monkey Report{{with .Name}}: {{.}}{{end}}
+{{with .Description}}
+{{.}}
+{{end}}
+| Setting | Value |
+| --- | --- |
+| Started | {{time .StartedAt}} |
+| Finished | {{time .FinishedAt}} |
+| Duration | {{.Duration}} |
+| Endpoint | {{.Endpoint}} |
+{{- with .Region}}
+| Region | {{.}} |
+{{- end}}
+{{- if .DryRun}}
+| Dry run | yes |
+{{- end}}
+{{- with .MinInService}}
+| Min in service | {{.}} |
+{{- end}}
+{{- with .CapacityWait}}
+| Capacity wait | {{.}} |
+{{- end}}
+{{- with .RecoveryTimeout}}
+| Recovery timeout | {{.}} |
+{{- end}}
+| Triggered events | {{.Triggered}} |
+| Skipped events | {{.Skipped}} |
+| Result | {{if .Error}}**Failed:** {{.Error}}{{else}}Succeeded{{end}} |
+{{range .Steps}}
+## Step {{.Number}}: {{if .Strategy}}{{.Strategy}}{{else}}default strategy{{end}} in group {{.Group}}
+{{with .Note}}
+{{.}}
+{{end}}
+Count {{.Count}}, interval {{.Interval}}, probability {{.Probability}}{{with .Duration}}, duration {{.}}{{end}}{{with .Region}}, region {{.}}{{end}}{{with .MinInService}}, min in service {{.}}{{end}}. Skipped {{.Skipped}} chaos event(s).
+{{if or .CapacityBefore .CapacityAfter}}
+| Capacity | Instances | Desired | Min | Max |
+| --- | --- | --- | --- | --- |
+{{- with .CapacityBefore}}
+| Before | {{.InstancesInService}} | {{.DesiredCapacity}} | {{.MinSize}} | {{.MaxSize}} |
+{{- end}}
+{{- with .CapacityAfter}}
+| After | {{.InstancesInService}} | {{.DesiredCapacity}} | {{.MinSize}} | {{.MaxSize}} |
+{{- end}}
+{{end}}
+{{- if .Events}}
+| InstanceID | AutoScalingGroupName | Region | Strategy | TriggeredAt |
+| --- | --- | --- | --- | --- |
+{{- range .Events}}
+| {{.InstanceID}} | {{.AutoScalingGroupName}} | {{.Region}} | {{.Strategy}} | {{time .TriggeredAt}} |
+{{- end}}
+{{end}}
+{{- if .Requests}}
+Would have sent {{len .Requests}} request(s) in dry run mode.
+{{end}}
+{{- end}}
+{{- if .Probes}}
+## Probes
+
+| Probe | After | Time | Duration | Result |
+| --- | --- | --- | --- | --- |
+{{- range .Probes}}
+| {{.Probe}} | {{if .After}}{{.After}}{{else}}steady state{{end}} | {{time .Time}} | {{.Duration}} | {{if .Error}}Failed: {{.Error}}{{else}}OK{{end}} |
+{{- end}}
+{{end}}`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(reportFuncs).Parse(
+	`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Chaos Monkey Report{{with .Name}}: {{.}}{{end}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+.failed { color: #c00; }
+</style>
+</head>
+<body>
+<h1>Chaos Monkey Report{{with .Name}}: {{.}}{{end}}</h1>
+{{with .Description}}<p>{{.}}</p>{{end}}
+<table>
+<tr><th>Started</th><td>{{time .StartedAt}}</td></tr>
+<tr><th>Finished</th><td>{{time .FinishedAt}}</td></tr>
+<tr><th>Duration</th><td>{{.Duration}}</td></tr>
+<tr><th>Endpoint</th><td>{{.Endpoint}}</td></tr>
+{{with .Region}}<tr><th>Region</th><td>{{.}}</td></tr>{{end}}
+{{if .DryRun}}<tr><th>Dry run</th><td>yes</td></tr>{{end}}
+{{with .MinInService}}<tr><th>Min in service</th><td>{{.}}</td></tr>{{end}}
+{{with .CapacityWait}}<tr><th>Capacity wait</th><td>{{.}}</td></tr>{{end}}
+{{with .RecoveryTimeout}}<tr><th>Recovery timeout</th><td>{{.}}</td></tr>{{end}}
+<tr><th>Triggered events</th><td>{{.Triggered}}</td></tr>
+<tr><th>Skipped events</th><td>{{.Skipped}}</td></tr>
+<tr><th>Result</th><td>{{if .Error}}<span class="failed">Failed: {{.Error}}</span>{{else}}Succeeded{{end}}</td></tr>
+</table>
+{{range .Steps}}
+<h2>Step {{.Number}}: {{if .Strategy}}{{.Strategy}}{{else}}default strategy{{end}} in group {{.Group}}</h2>
+{{with .Note}}<p>{{.}}</p>{{end}}
+<p>Count {{.Count}}, interval {{.Interval}}, probability {{.Probability}}{{with .Duration}}, duration {{.}}{{end}}{{with .Region}}, region {{.}}{{end}}{{with .MinInService}}, min in service {{.}}{{end}}. Skipped {{.Skipped}} chaos event(s).</p>
+{{if or .CapacityBefore .CapacityAfter}}
+<table>
+<tr><th>Capacity</th><th>Instances</th><th>Desired</th><th>Min</th><th>Max</th></tr>
+{{with .CapacityBefore}}<tr><td>Before</td><td>{{.InstancesInService}}</td><td>{{.DesiredCapacity}}</td><td>{{.MinSize}}</td><td>{{.MaxSize}}</td></tr>{{end}}
+{{with .CapacityAfter}}<tr><td>After</td><td>{{.InstancesInService}}</td><td>{{.DesiredCapacity}}</td><td>{{.MinSize}}</td><td>{{.MaxSize}}</td></tr>{{end}}
+</table>
+{{end}}
+{{if .Events}}
+<table>
+<tr><th>InstanceID</th><th>AutoScalingGroupName</th><th>Region</th><th>Strategy</th><th>TriggeredAt</th></tr>
+{{range .Events}}<tr><td>{{.InstanceID}}</td><td>{{.AutoScalingGroupName}}</td><td>{{.Region}}</td><td>{{.Strategy}}</td><td>{{time .TriggeredAt}}</td></tr>
+{{end}}</table>
+{{end}}
+{{if .Requests}}<p>Would have sent {{len .Requests}} request(s) in dry run mode.</p>{{end}}
+{{end}}
+{{if .Probes}}
+<h2>Probes</h2>
+<table>
+<tr><th>Probe</th><th>After</th><th>Time</th><th>Duration</th><th>Result</th></tr>
+{{range .Probes}}<tr><td>{{.Probe}}</td><td>{{if .After}}{{.After}}{{else}}steady state{{end}}</td><td>{{time .Time}}</td><td>{{.Duration}}</td><td>{{if .Error}}<span class="failed">Failed: {{.Error}}</span>{{else}}OK{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,407 @@
+package experiment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"time"
+
+	"github.com/mlafeldt/chaosmonkey/aws"
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+)
+
+// AutoScaling is used to look up auto scaling groups. It is implemented by
+// aws.Client.
+type AutoScaling interface {
+	AutoScalingGroup(name string) (*aws.AutoScalingGroup, error)
+	WaitForGroupHealthy(ctx context.Context, name string, timeout time.Duration) error
+	WaitForInstancesReplaced(ctx context.Context, name string, terminated []string, timeout time.Duration) error
+}
+
+// Runner executes experiments by triggering chaos events via the Chaos Monkey
+// API or another backend.
+type Runner struct {
+	// Configuration of the client used to trigger chaos events. The region
+	// of a step, if set, takes precedence over the configured one.
+	Config chaosmonkey.Config
+
+	// Optional function returning the backend that triggers chaos events in
+	// the given region, e.g. aws.Client (the Chaos Monkey API client created
+	// from Config by default)
+	Backend func(region string) chaosmonkey.Backend
+
+	// Optional writer for the log of executed steps
+	Log io.Writer
+
+	// Optional function called for every triggered chaos event
+	OnEvent func(chaosmonkey.Event)
+
+	// Optional source of random numbers in [0.0,1.0) used to decide whether
+	// to trigger a chaos event (rand.Float64 by default)
+	Random func() float64
+
+	// Optional function returning access to the auto scaling groups of the
+	// given region, which is used to check capacity and to resolve the group
+	// of each step in dry run mode
+	AutoScaling func(region string) AutoScaling
+
+	// Only log the requests that would be sent to the API instead of
+	// triggering chaos events
+	DryRun bool
+
+	// Minimum number of instances in service each auto scaling group must
+	// keep, unless overridden by a step. Capacity is checked before each
+	// chaos event, which requires AutoScaling.
+	MinInService Floor
+
+	// Time to wait for an auto scaling group to regain capacity if the
+	// next chaos event would drop it below its floor (fail immediately if
+	// zero)
+	CapacityWait time.Duration
+
+	// Interval for polling auto scaling groups (10 seconds by default)
+	PollInterval time.Duration
+
+	// Time to wait for an auto scaling group to recover after a chaos
+	// event, i.e. to have all desired instances in service again, before
+	// triggering the next one. The run is aborted if the group does not
+	// recover in time. Requires AutoScaling. Zero disables waiting.
+	RecoveryTimeout time.Duration
+
+	// Probes checking the steady state of the system in addition to the
+	// probes of the experiment
+	Probes []Probe
+
+	// Record the capacity of the auto scaling group before and after each
+	// step, if possible. Requires AutoScaling.
+	CaptureCapacity bool
+
+	// Notifiers informed about the start and end of the run as well as
+	// about triggered and skipped chaos events
+	Notifiers []Notifier
+
+	// Maximum time each notifier may take to send a notification,
+	// including retries (10 seconds by default)
+	NotifyTimeout time.Duration
+}
+
+// Summary describes the result of an experiment run.
+type Summary struct {
+	// Time when the run started and finished
+	StartedAt  time.Time
+	FinishedAt time.Time
+
+	Steps []StepResult
+
+	// Results of all probes in the order they were run
+	Probes []ProbeResult
+}
+
+// StepResult describes the result of a single step.
+type StepResult struct {
+	Step Step
+
+	// AWS region the step was executed in
+	Region string
+
+	// Capacity of the auto scaling group before and after the step, if
+	// recorded
+	CapacityBefore *aws.AutoScalingGroup
+	CapacityAfter  *aws.AutoScalingGroup
+
+	// Chaos events triggered by the step
+	Events []chaosmonkey.Event
+
+	// Number of chaos events skipped due to the step's probability
+	Skipped int
+
+	// Requests that would have been sent to the API in dry run mode. If a
+	// custom backend is used, they describe the chaos events it would
+	// have triggered.
+	Requests []chaosmonkey.APIRequest
+}
+
+// Triggered returns the total number of triggered chaos events.
+func (s *Summary) Triggered() int {
+	n := 0
+	for _, r := range s.Steps {
+		n += len(r.Events)
+	}
+	return n
+}
+
+// DryRun returns the total number of chaos events that would have been
+// triggered in dry run mode.
+func (s *Summary) DryRun() int {
+	n := 0
+	for _, r := range s.Steps {
+		n += len(r.Requests)
+	}
+	return n
+}
+
+// Skipped returns the total number of skipped chaos events.
+func (s *Summary) Skipped() int {
+	n := 0
+	for _, r := range s.Steps {
+		n += r.Skipped
+	}
+	return n
+}
+
+// Run executes the steps of the experiment in order. It stops at the first
+// error, in which case the returned summary contains the steps executed so far.
+func (r *Runner) Run(ctx context.Context, e *Experiment) (*Summary, error) {
+	summary := &Summary{StartedAt: time.Now()}
+
+	if err := e.Validate(); err != nil {
+		summary.FinishedAt = time.Now()
+		return summary, err
+	}
+
+	r.notify(ctx, e, Notification{Type: RunStarted})
+	err := r.run(ctx, e, summary)
+	summary.FinishedAt = time.Now()
+
+	n := Notification{
+		Type:      RunFinished,
+		Triggered: summary.Triggered(),
+		Skipped:   summary.Skipped(),
+	}
+	if err != nil {
+		n.Type = RunFailed
+		n.Error = err.Error()
+	}
+	r.notify(ctx, e, n)
+
+	return summary, err
+}
+
+func (r *Runner) run(ctx context.Context, e *Experiment, summary *Summary) error {
+	if e.Name != "" {
+		r.logf("Running experiment %q", e.Name)
+	}
+
+	if err := r.runProbes(ctx, e, summary, ""); err != nil {
+		return err
+	}
+
+	for i := range e.Steps {
+		step := e.Steps[i]
+		r.logf("Step %d/%d: %s", i+1, len(e.Steps), describeStep(&step))
+		if step.Note != "" {
+			r.logf("Note: %s", step.Note)
+		}
+
+		result, err := r.runStep(ctx, e, i+1, &step, summary)
+		summary.Steps = append(summary.Steps, *result)
+		if err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+
+		if step.Pause > 0 && i < len(e.Steps)-1 {
+			r.logf("Pausing for %s", step.Pause)
+			if err := r.wait(ctx, step.Pause); err != nil {
+				return err
+			}
+		}
+	}
+
+	if r.DryRun {
+		r.logf("Summary: would trigger %d chaos event(s), skipped %d chaos event(s)",
+			summary.DryRun(), summary.Skipped())
+	} else {
+		r.logf("Summary: triggered %d chaos event(s), skipped %d chaos event(s)",
+			summary.Triggered(), summary.Skipped())
+	}
+
+	return nil
+}
+
+func (r *Runner) runStep(ctx context.Context, e *Experiment, number int, step *Step, summary *Summary) (*StepResult, error) {
+	result := &StepResult{Step: *step}
+
+	config := r.Config
+	if step.Region != "" {
+		config.Region = step.Region
+	}
+	result.Region = config.Region
+	client, err := chaosmonkey.NewClient(&config)
+	if err != nil {
+		return result, err
+	}
+	var backend chaosmonkey.Backend = client
+	if r.Backend != nil {
+		backend = r.Backend(config.Region)
+	}
+
+	if r.CaptureCapacity {
+		result.CapacityBefore = r.captureCapacity(config.Region, step.Group)
+		defer func() {
+			result.CapacityAfter = r.captureCapacity(config.Region, step.Group)
+		}()
+	}
+
+	if r.DryRun && r.AutoScaling != nil {
+		g, err := r.AutoScaling(config.Region).AutoScalingGroup(step.Group)
+		if err != nil {
+			return result, err
+		}
+		r.logf("Group %s has %d instance(s) in service (desired %d, min %d, max %d)",
+			g.Name, g.InstancesInService, g.DesiredCapacity, g.MinSize, g.MaxSize)
+	}
+
+	random := r.Random
+	if random == nil {
+		random = rand.Float64
+	}
+
+	req := client.NewAPIRequest(step.Group, step.Strategy)
+	req.Duration = step.Duration
+
+	count := step.count()
+	for i := 1; i <= count; i++ {
+		if random() >= step.probability() {
+			result.Skipped++
+			r.logf("Skipped chaos event %d/%d with probability of %f", i, count, step.probability())
+			r.notify(ctx, e, Notification{
+				Type:        EventSkipped,
+				Step:        number,
+				Group:       step.Group,
+				Strategy:    step.Strategy,
+				Probability: step.probability(),
+			})
+		} else if err := r.checkCapacity(ctx, config.Region, step, result); err != nil {
+			return result, err
+		} else if r.DryRun {
+			body, err := json.MarshalIndent(req, "", "  ")
+			if err != nil {
+				return result, err
+			}
+			result.Requests = append(result.Requests, *req)
+			if r.Backend != nil {
+				reverted := ""
+				if req.Duration > 0 {
+					reverted = fmt.Sprintf(" reverted after %s", req.Duration)
+				}
+				r.logf("Would trigger chaos event %d/%d%s:\n%s", i, count, reverted, body)
+			} else {
+				r.logf("Would trigger chaos event %d/%d: POST %s%s\n%s",
+					i, count, config.Endpoint, chaosmonkey.APIPath, body)
+			}
+		} else {
+			event, err := chaosmonkey.TriggerRequest(ctx, backend, req)
+			if err != nil {
+				return result, err
+			}
+			result.Events = append(result.Events, *event)
+			r.logf("Triggered chaos event %d/%d on instance %s", i, count, event.InstanceID)
+			if r.OnEvent != nil {
+				r.OnEvent(*event)
+			}
+			r.notify(ctx, e, Notification{
+				Type:        EventTriggered,
+				Step:        number,
+				Group:       step.Group,
+				Strategy:    step.Strategy,
+				Probability: step.probability(),
+				Event:       event,
+			})
+			if err := r.runProbes(ctx, e, summary, event.InstanceID); err != nil {
+				return result, err
+			}
+		}
+		if i < count {
+			if err := r.wait(ctx, step.Interval); err != nil {
+				return result, err
+			}
+			if err := r.waitForRecovery(ctx, config.Region, step, result); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// captureCapacity returns the current state of the auto scaling group, or nil
+// if it cannot be retrieved.
+func (r *Runner) captureCapacity(region, group string) *aws.AutoScalingGroup {
+	if r.AutoScaling == nil {
+		return nil
+	}
+	g, err := r.AutoScaling(region).AutoScalingGroup(group)
+	if err != nil {
+		r.logf("Failed to capture capacity of group %s: %s", group, err)
+		return nil
+	}
+	return g
+}
+
+// waitForRecovery waits for the auto scaling group of the step to recover from
+// the chaos events of the step so far, if enabled.
+func (r *Runner) waitForRecovery(ctx context.Context, region string, step *Step, result *StepResult) error {
+	if r.RecoveryTimeout <= 0 || r.DryRun {
+		return nil
+	}
+	if r.AutoScaling == nil {
+		return errors.New("access to auto scaling groups is required to wait for recovery")
+	}
+	r.logf("Waiting up to %s for group %s to recover", r.RecoveryTimeout, step.Group)
+	// Only instances shut down by the step must be replaced; other chaos
+	// strategies leave the instance in service
+	var terminated []string
+	for _, e := range result.Events {
+		strategy := e.Strategy
+		if strategy == "" {
+			strategy = step.Strategy
+		}
+		if strategy == "" || strategy == chaosmonkey.StrategyShutdownInstance {
+			terminated = append(terminated, e.InstanceID)
+		}
+	}
+	if len(terminated) == 0 {
+		return r.AutoScaling(region).WaitForGroupHealthy(ctx, step.Group, r.RecoveryTimeout)
+	}
+	return r.AutoScaling(region).WaitForInstancesReplaced(ctx, step.Group, terminated, r.RecoveryTimeout)
+}
+
+func (r *Runner) logf(format string, a ...interface{}) {
+	w := r.Log
+	if w == nil {
+		w = ioutil.Discard
+	}
+	fmt.Fprintf(w, format+"\n", a...)
+}
+
+func describeStep(s *Step) string {
+	strategy := s.Strategy
+	if strategy == "" {
+		strategy = "default strategy"
+	}
+	desc := fmt.Sprintf("%s in group %s", strategy, s.Group)
+	if s.Region != "" {
+		desc += fmt.Sprintf(" (%s)", s.Region)
+	}
+	return desc + fmt.Sprintf(", count %d, probability %g", s.count(), s.probability())
+}
+
+// wait waits for the given duration or until the context is done. It returns
+// immediately in dry run mode.
+func (r *Runner) wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 || r.DryRun {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -12,6 +12,6 @@ class Chaosmonkey < Formula
   end
 
   test do
-    system "#{bin}/chaosmonkey --version"
+    system "#{bin}/chaosmonkey version"
   end
 end
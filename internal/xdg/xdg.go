@@ -0,0 +1,35 @@
+// Package xdg locates the files of the program in the user's directories as
+// defined by the XDG Base Directory Specification.
+package xdg
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ConfigFile returns the path of the file with the given name in the
+// program's configuration directory, i.e. ~/.config/chaosmonkey unless
+// XDG_CONFIG_HOME is set. It returns an empty string if the home directory
+// cannot be determined.
+func ConfigFile(name string) string {
+	return file("XDG_CONFIG_HOME", ".config", name)
+}
+
+// StateFile returns the path of the file with the given name in the program's
+// state directory, i.e. ~/.local/state/chaosmonkey unless XDG_STATE_HOME is
+// set. It returns an empty string if the home directory cannot be determined.
+func StateFile(name string) string {
+	return file("XDG_STATE_HOME", filepath.Join(".local", "state"), name)
+}
+
+func file(env, fallback, name string) string {
+	dir := os.Getenv(env)
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, fallback)
+	}
+	return filepath.Join(dir, "chaosmonkey", name)
+}
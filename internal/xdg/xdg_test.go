@@ -0,0 +1,32 @@
+package xdg_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/mlafeldt/chaosmonkey/internal/xdg"
+)
+
+func TestStateFile(t *testing.T) {
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", "/home/test")
+	os.Setenv("XDG_STATE_HOME", "")
+	if got, want := xdg.StateFile("audit.log"), "/home/test/.local/state/chaosmonkey/audit.log"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	os.Setenv("XDG_STATE_HOME", "/tmp/state")
+	defer os.Unsetenv("XDG_STATE_HOME")
+	if got, want := xdg.StateFile("journal.log"), "/tmp/state/chaosmonkey/journal.log"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", "/home/test")
+	os.Setenv("XDG_CONFIG_HOME", "")
+	defer os.Unsetenv("XDG_CONFIG_HOME")
+	if got, want := xdg.ConfigFile("config.yaml"), "/home/test/.config/chaosmonkey/config.yaml"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
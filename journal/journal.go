@@ -0,0 +1,171 @@
+/*
+Package journal records how to undo faults injected by the native AWS backend,
+so that they can be rolled back later, e.g. after the CLI was interrupted.
+
+The journal is stored locally as JSON lines. Every reversible fault is recorded
+when it is applied, and again when it is reverted. Faults that were applied but
+not reverted yet are pending. As faults with a duration are recorded along with
+it, the journal also tells which faults are overdue to be reverted.
+*/
+package journal
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mlafeldt/chaosmonkey/aws"
+	"github.com/mlafeldt/chaosmonkey/internal/xdg"
+)
+
+// Actions recorded in the journal
+const (
+	ActionApply  = "apply"
+	ActionRevert = "revert"
+)
+
+// Entry describes a fault that was applied or reverted.
+type Entry struct {
+	// Time when the action was performed
+	Time time.Time
+
+	// ID of the run that applied the fault, see NewRunID, and sequence
+	// number of the fault within the run. Together, they identify the
+	// fault.
+	RunID string
+	Seq   int
+
+	// ActionApply or ActionRevert
+	Action string
+
+	// Fault applied, including the original state to restore
+	Fault *aws.Fault
+}
+
+// NewRunID returns a new unique ID for a run, which starts with the current
+// time, e.g. "20060102T150405Z-8f3a2c".
+func NewRunID() string {
+	b := make([]byte, 3)
+	rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b)
+}
+
+// Journal is an append-only journal of faults.
+type Journal struct {
+	file *os.File
+}
+
+// DefaultPath returns the default location of the journal, which is in the
+// user's state directory as defined by the XDG Base Directory Specification.
+func DefaultPath() string {
+	return xdg.StateFile("journal.log")
+}
+
+// Open opens the journal at the given path for appending, creating it and its
+// directory if necessary.
+func Open(path string) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{file: f}, nil
+}
+
+// Record appends the entry to the journal. The time is filled in if not set.
+// It is safe for concurrent use, as each entry is written at once.
+func (j *Journal) Record(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = j.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the journal.
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
+
+// Pending returns the entries of faults in the journal at the given path that
+// were applied, but not reverted yet, most recent first, i.e. in the order they
+// should be reverted. If runID is not empty, only faults of that run are
+// returned. It returns no entries if the journal does not exist.
+func Pending(filename, runID string) ([]Entry, error) {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	type key struct {
+		runID string
+		seq   int
+	}
+	var (
+		applied  []Entry
+		reverted = make(map[key]bool)
+	)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", filename, n, err)
+		}
+		if runID != "" && e.RunID != runID {
+			continue
+		}
+		switch e.Action {
+		case ActionApply:
+			applied = append(applied, e)
+		case ActionRevert:
+			reverted[key{e.RunID, e.Seq}] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var pending []Entry
+	for i := len(applied) - 1; i >= 0; i-- {
+		if !reverted[key{applied[i].RunID, applied[i].Seq}] {
+			pending = append(pending, applied[i])
+		}
+	}
+	return pending, nil
+}
+
+// Expired returns the entries of pending faults in the journal at the given
+// path whose duration has passed at the given time, see aws.Fault.Expires, most
+// recent first.
+func Expired(filename string, now time.Time) ([]Entry, error) {
+	pending, err := Pending(filename, "")
+	if err != nil {
+		return nil, err
+	}
+	var expired []Entry
+	for _, e := range pending {
+		if t := e.Fault.Expires(); !t.IsZero() && !t.After(now) {
+			expired = append(expired, e)
+		}
+	}
+	return expired, nil
+}
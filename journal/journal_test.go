@@ -0,0 +1,154 @@
+package journal_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/mlafeldt/chaosmonkey/aws"
+	"github.com/mlafeldt/chaosmonkey/journal"
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+)
+
+func fault(instanceID string, strategy chaosmonkey.Strategy) *aws.Fault {
+	return &aws.Fault{
+		Event: chaosmonkey.Event{
+			InstanceID:           instanceID,
+			AutoScalingGroupName: "web-asg",
+			Region:               "us-east-1",
+			Strategy:             strategy,
+			TriggeredAt:          time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+		},
+		RevertScript: "pkill -f chaosmonkey\n",
+	}
+}
+
+func TestPending(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state", "journal.log")
+
+	if pending, err := journal.Pending(path, ""); err != nil || len(pending) != 0 {
+		t.Fatalf("expected no pending faults without journal, got %v, %v", pending, err)
+	}
+
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	entries := []journal.Entry{
+		{Time: start, RunID: "run1", Seq: 1, Action: journal.ActionApply, Fault: fault("i-1", chaosmonkey.StrategyBurnCPU)},
+		{Time: start, RunID: "run1", Seq: 2, Action: journal.ActionApply, Fault: fault("i-2", chaosmonkey.StrategyFailDNS)},
+		{Time: start, RunID: "run2", Seq: 1, Action: journal.ActionApply, Fault: fault("i-3", chaosmonkey.StrategyNetworkLoss)},
+		{Time: start, RunID: "run1", Seq: 1, Action: journal.ActionRevert, Fault: fault("i-1", chaosmonkey.StrategyBurnCPU)},
+	}
+
+	// Reopen the journal for every entry to make sure entries are appended
+	for _, e := range entries {
+		j, err := journal.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := j.Record(e); err != nil {
+			t.Fatal(err)
+		}
+		if err := j.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []struct {
+		runID string
+		want  []journal.Entry
+	}{
+		{"", []journal.Entry{entries[2], entries[1]}},
+		{"run1", []journal.Entry{entries[1]}},
+		{"run2", []journal.Entry{entries[2]}},
+		{"run3", nil},
+	}
+	for _, test := range tests {
+		got, err := journal.Pending(path, test.runID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("run ID %q: %s", test.runID, diff)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("want mode 0600, got %o", perm)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "journal.log")
+
+	j, err := journal.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	var entries []journal.Entry
+	for i, d := range []time.Duration{0, time.Minute, time.Hour, time.Minute} {
+		f := fault(fmt.Sprintf("i-%d", i+1), chaosmonkey.StrategyBurnCPU)
+		f.Duration = d
+		entries = append(entries, journal.Entry{RunID: "run1", Seq: i + 1, Action: journal.ActionApply, Fault: f})
+	}
+	reverted := entries[3]
+	reverted.Action = journal.ActionRevert
+	for _, e := range append(entries, reverted) {
+		if err := j.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start := entries[0].Fault.TriggeredAt
+	var tests = []struct {
+		now  time.Time
+		want []string
+	}{
+		{start, nil},
+		{start.Add(time.Minute), []string{"i-2"}},
+		{start.Add(2 * time.Hour), []string{"i-3", "i-2"}},
+	}
+	for _, test := range tests {
+		expired, err := journal.Expired(path, test.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range expired {
+			got = append(got, e.Fault.InstanceID)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("at %s: %s", test.now, diff)
+		}
+	}
+}
+
+func TestNewRunID(t *testing.T) {
+	id := journal.NewRunID()
+	if !regexp.MustCompile(`^\d{8}T\d{6}Z-[0-9a-f]{6}$`).MatchString(id) {
+		t.Fatalf("unexpected run ID %q", id)
+	}
+	if id == journal.NewRunID() {
+		t.Fatal("run IDs must be unique")
+	}
+}
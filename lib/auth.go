@@ -0,0 +1,266 @@
+package chaosmonkey
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authenticator authenticates requests sent to the Chaos Monkey API.
+type Authenticator interface {
+	// Authenticate adds credentials to the request, e.g. as HTTP header.
+	Authenticate(req *http.Request) error
+}
+
+// TLSAuthenticator is an Authenticator that requires a custom TLS
+// configuration, e.g. for client certificates. NewClient applies the TLS
+// configuration to the HTTP client.
+type TLSAuthenticator interface {
+	Authenticator
+	ConfigureTLS(config *tls.Config) error
+}
+
+// BasicAuth authenticates requests with HTTP Basic Authentication. It is used
+// by default if Config has a username and password.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Authenticate implements Authenticator.
+func (a *BasicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(a.Username, a.Password)
+	return nil
+}
+
+// BearerToken authenticates requests with a static bearer token, as expected
+// by many authentication proxies.
+type BearerToken struct {
+	Token string
+}
+
+// Authenticate implements Authenticator.
+func (a *BearerToken) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	return nil
+}
+
+// TokenCommand authenticates requests with a bearer token printed by a command,
+// which is run via "sh -c". The command may either print the token itself or a
+// JSON object of the form:
+//
+//	{"token": "...", "expiry": "2006-01-02T15:04:05Z"}
+//
+// The token is cached until it expires or, if the command does not specify an
+// expiry, for TTL. It is also refreshed if the API rejects it.
+type TokenCommand struct {
+	Command string
+
+	// Time to cache tokens without expiry (5 minutes by default)
+	TTL time.Duration
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// DefaultTokenTTL is the default time tokens of TokenCommand are cached.
+const DefaultTokenTTL = 5 * time.Minute
+
+// Authenticate implements Authenticator.
+func (a *TokenCommand) Authenticate(req *http.Request) error {
+	token, err := a.Token()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Token returns the cached token, running the command if there is none or it
+// has expired.
+func (a *TokenCommand) Token() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && time.Now().Before(a.expires) {
+		return a.token, nil
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", a.Command)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("token command failed: %s: %s", err, msg)
+		}
+		return "", fmt.Errorf("token command failed: %s", err)
+	}
+
+	token, expires := strings.TrimSpace(string(out)), time.Time{}
+	if strings.HasPrefix(token, "{") {
+		var v struct {
+			Token  string    `json:"token"`
+			Expiry time.Time `json:"expiry"`
+		}
+		if err := json.Unmarshal(out, &v); err != nil {
+			return "", fmt.Errorf("invalid output of token command: %s", err)
+		}
+		token, expires = v.Token, v.Expiry
+	}
+	if token == "" {
+		return "", errors.New("token command printed no token")
+	}
+	if expires.IsZero() {
+		ttl := a.TTL
+		if ttl <= 0 {
+			ttl = DefaultTokenTTL
+		}
+		expires = time.Now().Add(ttl)
+	}
+	a.token, a.expires = token, expires
+	return token, nil
+}
+
+// Invalidate discards the cached token.
+func (a *TokenCommand) Invalidate() {
+	a.mu.Lock()
+	a.token = ""
+	a.mu.Unlock()
+}
+
+// ClientCertificate authenticates with a TLS client certificate (mutual TLS)
+// and/or verifies the server with custom CA certificates. As it only affects
+// the TLS handshake, it is usually set as Config.TLS, so that it can be
+// combined with another Authenticator.
+type ClientCertificate struct {
+	// Paths of PEM-encoded client certificate and private key
+	CertFile string
+	KeyFile  string
+
+	// Optional path of PEM-encoded CA certificates used to verify the
+	// server (system CAs by default)
+	CAFile string
+}
+
+// Authenticate implements Authenticator. Authentication happens as part of
+// the TLS handshake, see ConfigureTLS.
+func (a *ClientCertificate) Authenticate(req *http.Request) error {
+	return nil
+}
+
+// ConfigureTLS implements TLSAuthenticator.
+func (a *ClientCertificate) ConfigureTLS(config *tls.Config) error {
+	if a.CertFile != "" || a.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(a.CertFile, a.KeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load client certificate: %s", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if a.CAFile != "" {
+		pem, err := ioutil.ReadFile(a.CAFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no CA certificates found in %s", a.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	return nil
+}
+
+// withTLS returns a copy of the HTTP client using the TLS configuration of the
+// authenticator.
+func withTLS(client *http.Client, a TLSAuthenticator) (*http.Client, error) {
+	var transport *http.Transport
+	switch t := client.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return nil, fmt.Errorf("cannot configure TLS of HTTP transport %T", t)
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	if err := a.ConfigureTLS(transport.TLSClientConfig); err != nil {
+		return nil, err
+	}
+	c := *client
+	c.Transport = transport
+	return &c, nil
+}
+
+// AuthOptions select an Authenticator other than BasicAuth and the TLS
+// configuration of the client, as configured in profiles or via the CLI.
+type AuthOptions struct {
+	// Static bearer token, see BearerToken
+	Token string `yaml:"token"`
+
+	// Command printing a bearer token and time to cache tokens, see
+	// TokenCommand
+	TokenCommand string        `yaml:"token-command"`
+	TokenTTL     time.Duration `yaml:"token-ttl"`
+
+	// Paths of client certificate, private key, and CA certificates, see
+	// ClientCertificate
+	ClientCert string `yaml:"client-cert"`
+	ClientKey  string `yaml:"client-key"`
+	CACert     string `yaml:"ca-cert"`
+}
+
+// Authenticator returns the Authenticator selected by the options, or nil if
+// none is selected. It is an error to select more than one. TLS options are
+// not an Authenticator, see TLS.
+func (o *AuthOptions) Authenticator() (Authenticator, error) {
+	var selected []Authenticator
+	if o.Token != "" {
+		selected = append(selected, &BearerToken{Token: o.Token})
+	}
+	if o.TokenCommand != "" {
+		selected = append(selected, &TokenCommand{Command: o.TokenCommand, TTL: o.TokenTTL})
+	}
+	switch len(selected) {
+	case 0:
+		return nil, nil
+	case 1:
+		return selected[0], nil
+	}
+	return nil, errors.New("only one of token and token command may be used")
+}
+
+// TLS returns the client certificate and CA certificates selected by the
+// options, to be used as Config.TLS, or nil if none are selected.
+func (o *AuthOptions) TLS() (*ClientCertificate, error) {
+	if o.ClientCert == "" && o.ClientKey == "" && o.CACert == "" {
+		return nil, nil
+	}
+	if (o.ClientCert == "") != (o.ClientKey == "") {
+		return nil, errors.New("client certificate and key must be given together")
+	}
+	return &ClientCertificate{
+		CertFile: o.ClientCert,
+		KeyFile:  o.ClientKey,
+		CAFile:   o.CACert,
+	}, nil
+}
+
+// invalidator is implemented by authenticators whose credentials can be
+// refreshed after being rejected.
+type invalidator interface {
+	Invalidate()
+}
@@ -0,0 +1,298 @@
+package chaosmonkey_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+)
+
+// tokenServer accepts requests carrying one of the given bearer tokens.
+func tokenServer(t *testing.T, tokens ...string) (*httptest.Server, *[]string) {
+	var (
+		mu   sync.Mutex
+		seen []string
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		mu.Lock()
+		seen = append(seen, auth)
+		mu.Unlock()
+		for _, token := range tokens {
+			if auth == "Bearer "+token {
+				fmt.Fprint(w, "[]")
+				return
+			}
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	return s, &seen
+}
+
+func TestBearerToken(t *testing.T) {
+	s, _ := tokenServer(t, "s3cr3t")
+	defer s.Close()
+
+	client, err := chaosmonkey.NewClient(&chaosmonkey.Config{
+		Endpoint:      s.URL,
+		Authenticator: &chaosmonkey.BearerToken{Token: "s3cr3t"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Events(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTokenCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The command prints a new token on every run: token-1, token-2, ...
+	counter := filepath.Join(dir, "counter")
+	command := fmt.Sprintf(`n=$(($(cat %[1]s 2>/dev/null || echo 0) + 1)); echo $n > %[1]s; echo token-$n`, counter)
+
+	s, seen := tokenServer(t, "token-1", "token-3")
+	defer s.Close()
+
+	auth := &chaosmonkey.TokenCommand{Command: command}
+	client, err := chaosmonkey.NewClient(&chaosmonkey.Config{
+		Endpoint:      s.URL,
+		Authenticator: auth,
+		RetryPolicy:   &chaosmonkey.RetryPolicy{MaxAttempts: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first token is cached
+	for i := 0; i < 2; i++ {
+		if _, err := client.Events(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An expired token is refreshed, and a rejected one once more
+	auth.TTL = time.Nanosecond
+	auth.Invalidate()
+	if _, err := client.Events(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"Bearer token-1", "Bearer token-1", "Bearer token-2", "Bearer token-3"}
+	if strings.Join(*seen, ",") != strings.Join(want, ",") {
+		t.Errorf("want %v, got %v", want, *seen)
+	}
+}
+
+func TestTokenCommandJSON(t *testing.T) {
+	expiry := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	auth := &chaosmonkey.TokenCommand{
+		Command: fmt.Sprintf(`echo '{"token": "json-token", "expiry": "%s"}'`, expiry),
+	}
+	token, err := auth.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "json-token" {
+		t.Errorf("want json-token, got %q", token)
+	}
+
+	auth = &chaosmonkey.TokenCommand{Command: "exit 1"}
+	if _, err := auth.Token(); err == nil {
+		t.Error("expected error for failing token command")
+	}
+}
+
+func TestClientCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, caKey := newCertificate(t, nil, nil, "ca")
+	server, serverKey := newCertificate(t, ca, caKey, "server")
+	client, clientKey := newCertificate(t, ca, caKey, "client")
+	caFile := writePEM(t, dir, "ca.pem", "CERTIFICATE", ca.Raw)
+	certFile := writePEM(t, dir, "client.pem", "CERTIFICATE", client.Raw)
+	keyBytes, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", keyBytes)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "[]")
+	}))
+	s.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	s.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{server.Raw}, PrivateKey: serverKey}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	s.StartTLS()
+	defer s.Close()
+
+	var tests = []struct {
+		auth    chaosmonkey.Authenticator
+		success bool
+	}{
+		{&chaosmonkey.ClientCertificate{CertFile: certFile, KeyFile: keyFile, CAFile: caFile}, true},
+		{&chaosmonkey.ClientCertificate{CAFile: caFile}, false},
+		{nil, false},
+	}
+	for i, tt := range tests {
+		c, err := chaosmonkey.NewClient(&chaosmonkey.Config{
+			Endpoint:      s.URL,
+			Authenticator: tt.auth,
+			HTTPClient:    &http.Client{Timeout: 5 * time.Second},
+			RetryPolicy:   &chaosmonkey.RetryPolicy{MaxAttempts: 1},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Events(); (err == nil) != tt.success {
+			t.Errorf("%d. want success %v, got error %v", i, tt.success, err)
+		}
+	}
+
+	// The TLS configuration is also applied to the default HTTP client
+	c, err := chaosmonkey.NewClient(&chaosmonkey.Config{
+		Endpoint:      s.URL,
+		Authenticator: tests[0].auth,
+		RetryPolicy:   &chaosmonkey.RetryPolicy{MaxAttempts: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Events(); err != nil {
+		t.Errorf("default HTTP client: %s", err)
+	}
+
+	// CA certificates only verify the server and keep basic authentication
+	basic := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "u" || p != "p" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "[]")
+	}))
+	basic.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	basic.TLS = &tls.Config{Certificates: s.TLS.Certificates}
+	basic.StartTLS()
+	defer basic.Close()
+	c, err = chaosmonkey.NewClient(&chaosmonkey.Config{
+		Endpoint:    basic.URL,
+		Username:    "u",
+		Password:    "p",
+		TLS:         &chaosmonkey.ClientCertificate{CAFile: caFile},
+		RetryPolicy: &chaosmonkey.RetryPolicy{MaxAttempts: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Events(); err != nil {
+		t.Errorf("CA certificates with basic authentication: %s", err)
+	}
+}
+
+func TestAuthOptions(t *testing.T) {
+	var tests = []struct {
+		opts     chaosmonkey.AuthOptions
+		wantAuth string
+		wantTLS  string
+	}{
+		{chaosmonkey.AuthOptions{}, "<nil>", "<nil>"},
+		{chaosmonkey.AuthOptions{Token: "x"}, "*chaosmonkey.BearerToken", "<nil>"},
+		{chaosmonkey.AuthOptions{TokenCommand: "x"}, "*chaosmonkey.TokenCommand", "<nil>"},
+		{chaosmonkey.AuthOptions{Token: "x", TokenCommand: "x"}, "error", "<nil>"},
+		{chaosmonkey.AuthOptions{ClientCert: "c", ClientKey: "k"}, "<nil>", "*chaosmonkey.ClientCertificate"},
+		{chaosmonkey.AuthOptions{CACert: "ca"}, "<nil>", "*chaosmonkey.ClientCertificate"},
+		{chaosmonkey.AuthOptions{ClientCert: "c"}, "<nil>", "error"},
+		{chaosmonkey.AuthOptions{Token: "x", ClientCert: "c", ClientKey: "k"}, "*chaosmonkey.BearerToken", "*chaosmonkey.ClientCertificate"},
+	}
+	describe := func(v interface{}, isNil bool, err error) string {
+		switch {
+		case err != nil:
+			return "error"
+		case isNil:
+			return "<nil>"
+		}
+		return fmt.Sprintf("%T", v)
+	}
+	for i, tt := range tests {
+		a, err := tt.opts.Authenticator()
+		if got := describe(a, a == nil, err); got != tt.wantAuth {
+			t.Errorf("%d. want authenticator %s, got %s", i, tt.wantAuth, got)
+		}
+		tls, err := tt.opts.TLS()
+		if got := describe(tls, tls == nil, err); got != tt.wantTLS {
+			t.Errorf("%d. want TLS %s, got %s", i, tt.wantTLS, got)
+		}
+	}
+}
+
+// newCertificate returns a certificate signed by the parent, or a self-signed
+// CA certificate if parent is nil.
+func newCertificate(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, name string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func writePEM(t *testing.T, dir, name, typ string, der []byte) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,42 @@
+package chaosmonkey
+
+import (
+	"context"
+	"errors"
+)
+
+// Backend triggers chaos events. Client is the Backend talking to the Simian
+// Army REST API; other implementations inject failures directly, e.g. the
+// native AWS backend of package aws.
+type Backend interface {
+	TriggerEventContext(ctx context.Context, group string, strategy Strategy) (*Event, error)
+}
+
+// RequestBackend is a Backend that triggers chaos events described by an
+// APIRequest, which allows for options such as APIRequest.Duration.
+type RequestBackend interface {
+	Backend
+	TriggerRequestContext(ctx context.Context, req *APIRequest) (*Event, error)
+}
+
+// Errors returned by backends that cannot apply the requested chaos strategy,
+// or cannot revert chaos events after the requested duration
+var (
+	ErrUnsupportedStrategy = errors.New("chaos strategy not supported by backend")
+	ErrUnsupportedDuration = errors.New("duration not supported by backend")
+)
+
+// TriggerRequest triggers the chaos event described by the request via the
+// backend. Only a RequestBackend may support a duration; ErrUnsupportedDuration
+// is returned for other backends.
+func TriggerRequest(ctx context.Context, b Backend, req *APIRequest) (*Event, error) {
+	if rb, ok := b.(RequestBackend); ok {
+		return rb.TriggerRequestContext(ctx, req)
+	}
+	if req.Duration != 0 {
+		return nil, ErrUnsupportedDuration
+	}
+	return b.TriggerEventContext(ctx, req.GroupName, Strategy(req.ChaosType))
+}
+
+var _ RequestBackend = (*Client)(nil)
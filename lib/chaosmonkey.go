@@ -20,6 +20,9 @@ Similar, the client can be used to retrieve information about past chaos events:
 	events, err := client.Events()
 	...
 
+Each of these methods has a Context variant, e.g. TriggerEventContext, which
+allows to cancel the request or bind it to a deadline.
+
 Note that in order to trigger chaos events, Chaos Monkey must be unleashed and
 on-demand termination must be enabled via these configuration properties:
 
@@ -30,6 +33,7 @@ package chaosmonkey
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -51,6 +55,10 @@ type APIRequest struct {
 	GroupName string `json:"groupName"`
 	GroupType string `json:"groupType"`
 	Region    string `json:"region,omitempty"` // Ignored by vanilla Chaos Monkey
+
+	// Time after which the chaos event is reverted, which is not supported
+	// by Simian Army and thus not sent to the API, see RequestBackend
+	Duration time.Duration `json:"-"`
 }
 
 // APIResponse describes a response returned by the API.
@@ -109,22 +117,52 @@ type Config struct {
 	// Optional password for HTTP Basic Authentication
 	Password string
 
+	// Optional sources of the password if Password is empty, see
+	// ResolvePassword
+	PasswordFile    string
+	PasswordCommand string
+	NetrcFile       string
+
+	// Optional authentication of requests, e.g. BearerToken (BasicAuth with
+	// Username and Password by default)
+	Authenticator Authenticator
+
+	// Optional TLS configuration of the HTTP client, e.g. a
+	// ClientCertificate, applied in addition to Authenticator
+	TLS TLSAuthenticator
+
 	// Custom HTTP User Agent
 	UserAgent string
 
 	// Custom HTTP client to use (http.DefaultClient by default)
 	HTTPClient *http.Client
+
+	// Custom policy for retrying failed requests (DefaultRetryPolicy() by
+	// default)
+	RetryPolicy *RetryPolicy
 }
 
 // DefaultConfig returns a default configuration for the client. It parses the
-// environment variables CHAOSMONKEY_ENDPOINT, CHAOSMONKEY_USERNAME, and
-// CHAOSMONKEY_PASSWORD.
+// environment variables CHAOSMONKEY_ENDPOINT, CHAOSMONKEY_USERNAME,
+// CHAOSMONKEY_PASSWORD, CHAOSMONKEY_PASSWORD_FILE, and
+// CHAOSMONKEY_PASSWORD_COMMAND, and falls back to the netrc file for the
+// password.
 func DefaultConfig() *Config {
 	c := Config{
-		Endpoint:   "http://127.0.0.1:8080",
-		UserAgent:  "chaosmonkey Go library",
-		HTTPClient: http.DefaultClient,
+		Endpoint:    "http://127.0.0.1:8080",
+		UserAgent:   "chaosmonkey Go library",
+		HTTPClient:  http.DefaultClient,
+		RetryPolicy: DefaultRetryPolicy(),
+		NetrcFile:   DefaultNetrcFile(),
 	}
+	c.readEnv()
+	return &c
+}
+
+// readEnv overrides the configuration with the values of the environment
+// variables CHAOSMONKEY_ENDPOINT, CHAOSMONKEY_USERNAME, CHAOSMONKEY_PASSWORD,
+// CHAOSMONKEY_PASSWORD_FILE, and CHAOSMONKEY_PASSWORD_COMMAND, if set.
+func (c *Config) readEnv() {
 	if v := os.Getenv("CHAOSMONKEY_ENDPOINT"); v != "" {
 		c.Endpoint = v
 	}
@@ -134,7 +172,12 @@ func DefaultConfig() *Config {
 	if v := os.Getenv("CHAOSMONKEY_PASSWORD"); v != "" {
 		c.Password = v
 	}
-	return &c
+	if v := os.Getenv("CHAOSMONKEY_PASSWORD_FILE"); v != "" {
+		c.PasswordFile = v
+	}
+	if v := os.Getenv("CHAOSMONKEY_PASSWORD_COMMAND"); v != "" {
+		c.PasswordCommand = v
+	}
 }
 
 // Client is the client to the Chaos Monkey API. Create a client with NewClient.
@@ -157,12 +200,39 @@ func NewClient(c *Config) (*Client, error) {
 	if c.Password == "" {
 		c.Password = defConfig.Password
 	}
-	if c.UserAgent == "" {
-		c.UserAgent = defConfig.UserAgent
+	if c.PasswordFile == "" && c.PasswordCommand == "" {
+		c.PasswordFile = defConfig.PasswordFile
+		c.PasswordCommand = defConfig.PasswordCommand
+	}
+	if c.NetrcFile == "" {
+		c.NetrcFile = defConfig.NetrcFile
+	}
+	if c.Authenticator == nil {
+		if err := c.ResolvePassword(); err != nil {
+			return nil, err
+		}
+		if c.Username != "" && c.Password != "" {
+			c.Authenticator = &BasicAuth{Username: c.Username, Password: c.Password}
+		}
 	}
 	if c.HTTPClient == nil {
 		c.HTTPClient = defConfig.HTTPClient
 	}
+	for _, a := range []interface{}{c.TLS, c.Authenticator} {
+		if a, ok := a.(TLSAuthenticator); ok {
+			client, err := withTLS(c.HTTPClient, a)
+			if err != nil {
+				return nil, err
+			}
+			c.HTTPClient = client
+		}
+	}
+	if c.UserAgent == "" {
+		c.UserAgent = defConfig.UserAgent
+	}
+	if c.RetryPolicy == nil {
+		c.RetryPolicy = defConfig.RetryPolicy
+	}
 	return &Client{config: c}, nil
 }
 
@@ -170,42 +240,74 @@ func NewClient(c *Config) (*Client, error) {
 // "break" an EC2 instance in the given auto scaling group using the specified
 // chaos strategy.
 func (c *Client) TriggerEvent(group string, strategy Strategy) (*Event, error) {
+	return c.TriggerEventContext(context.Background(), group, strategy)
+}
+
+// TriggerEventContext is like TriggerEvent, but the request is bound to the
+// given context.
+func (c *Client) TriggerEventContext(ctx context.Context, group string, strategy Strategy) (*Event, error) {
+	return c.TriggerRequestContext(ctx, c.NewAPIRequest(group, strategy))
+}
+
+// TriggerRequestContext sends the request, e.g. as returned by NewAPIRequest,
+// to the API. As Simian Army cannot revert chaos events, ErrUnsupportedDuration
+// is returned if the request has a duration.
+func (c *Client) TriggerRequestContext(ctx context.Context, req *APIRequest) (*Event, error) {
+	if req.Duration != 0 {
+		return nil, ErrUnsupportedDuration
+	}
 	url := c.config.Endpoint + APIPath
 
-	body, err := json.Marshal(APIRequest{
-		EventType: "CHAOS_TERMINATION",
-		GroupType: "ASG",
-		GroupName: group,
-		ChaosType: string(strategy),
-		Region:    c.config.Region,
-	})
+	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
 	}
 
 	var resp APIResponse
-	if err := c.sendRequest("POST", url, bytes.NewReader(body), &resp); err != nil {
+	if err := c.sendRequestWithRetry(ctx, "POST", url, body, &resp); err != nil {
 		return nil, err
 	}
 
 	return resp.ToEvent(), nil
 }
 
+// NewAPIRequest returns the request sent to the API by TriggerEvent.
+func (c *Client) NewAPIRequest(group string, strategy Strategy) *APIRequest {
+	return &APIRequest{
+		EventType: "CHAOS_TERMINATION",
+		GroupType: "ASG",
+		GroupName: group,
+		ChaosType: string(strategy),
+		Region:    c.config.Region,
+	}
+}
+
 // Events returns a list of all chaos events.
 func (c *Client) Events() ([]Event, error) {
-	return c.events(0)
+	return c.EventsContext(context.Background())
+}
+
+// EventsContext is like Events, but the request is bound to the given context.
+func (c *Client) EventsContext(ctx context.Context) ([]Event, error) {
+	return c.events(ctx, 0)
 }
 
 // EventsSince returns a list of all chaos events since a specific time.
 func (c *Client) EventsSince(t time.Time) ([]Event, error) {
-	return c.events(t.UTC().Unix() * 1000)
+	return c.EventsSinceContext(context.Background(), t)
+}
+
+// EventsSinceContext is like EventsSince, but the request is bound to the
+// given context.
+func (c *Client) EventsSinceContext(ctx context.Context, t time.Time) ([]Event, error) {
+	return c.events(ctx, t.UTC().Unix()*1000)
 }
 
-func (c *Client) events(since int64) ([]Event, error) {
+func (c *Client) events(ctx context.Context, since int64) ([]Event, error) {
 	url := fmt.Sprintf("%s%s?since=%d", c.config.Endpoint, APIPath, since)
 
 	var resp []APIResponse
-	if err := c.sendRequest("GET", url, nil, &resp); err != nil {
+	if err := c.sendRequestWithRetry(ctx, "GET", url, nil, &resp); err != nil {
 		return nil, err
 	}
 
@@ -217,20 +319,18 @@ func (c *Client) events(since int64) ([]Event, error) {
 	return events, nil
 }
 
-func (c *Client) sendRequest(method, url string, body io.Reader, out interface{}) error {
-	req, err := http.NewRequest(method, url, body)
+func (c *Client) sendRequest(ctx context.Context, method, url string, body []byte, out interface{}) error {
+	resp, err := c.do(ctx, method, url, body)
 	if err != nil {
 		return err
 	}
-
-	if c.config.Username != "" && c.config.Password != "" {
-		req.SetBasicAuth(c.config.Username, c.config.Password)
-	}
-	req.Header.Add("User-Agent", c.config.UserAgent)
-
-	resp, err := c.config.HTTPClient.Do(req)
-	if err != nil {
-		return err
+	// Refresh rejected credentials once
+	if a, ok := c.config.Authenticator.(invalidator); ok && resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		a.Invalidate()
+		if resp, err = c.do(ctx, method, url, body); err != nil {
+			return err
+		}
 	}
 	defer resp.Body.Close()
 
@@ -241,11 +341,22 @@ func (c *Client) sendRequest(method, url string, body io.Reader, out interface{}
 	return json.NewDecoder(resp.Body).Decode(out)
 }
 
-func decodeError(resp *http.Response) error {
-	var r APIResponse
-	err := json.NewDecoder(resp.Body).Decode(&r)
-	if err == nil && r.Message != "" {
-		return fmt.Errorf("%s", r.Message)
+func (c *Client) do(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
 	}
-	return fmt.Errorf("HTTP error: %s", resp.Status)
+	req, err := http.NewRequestWithContext(ctx, method, url, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.config.Authenticator != nil {
+		if err := c.config.Authenticator.Authenticate(req); err != nil {
+			return nil, err
+		}
+	}
+	req.Header.Add("User-Agent", c.config.UserAgent)
+
+	return c.config.HTTPClient.Do(req)
 }
@@ -1,6 +1,8 @@
 package chaosmonkey_test
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
@@ -99,6 +101,34 @@ func TestTriggerEvent(t *testing.T) {
 	}
 }
 
+// eventBackend is a Backend that doesn't implement RequestBackend.
+type eventBackend struct{}
+
+func (eventBackend) TriggerEventContext(ctx context.Context, group string, strategy chaosmonkey.Strategy) (*chaosmonkey.Event, error) {
+	return &chaosmonkey.Event{AutoScalingGroupName: group, Strategy: strategy}, nil
+}
+
+func TestTriggerRequest(t *testing.T) {
+	ctx := context.Background()
+	req := client.NewAPIRequest("SomeAutoScalingGroup", chaosmonkey.StrategyBurnCPU)
+
+	for _, b := range []chaosmonkey.Backend{client, eventBackend{}} {
+		event, err := chaosmonkey.TriggerRequest(ctx, b, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.AutoScalingGroupName != "SomeAutoScalingGroup" {
+			t.Errorf("unexpected event %+v", event)
+		}
+
+		timed := *req
+		timed.Duration = 5 * time.Minute
+		if _, err := chaosmonkey.TriggerRequest(ctx, b, &timed); !errors.Is(err, chaosmonkey.ErrUnsupportedDuration) {
+			t.Errorf("%T: expected ErrUnsupportedDuration, got %v", b, err)
+		}
+	}
+}
+
 func TestEvents(t *testing.T) {
 	events, err := client.Events()
 	if err != nil {
@@ -126,3 +156,57 @@ func TestEvents(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+func TestContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := client.TriggerEventContext(ctx, "SomeAutoScalingGroup", chaosmonkey.StrategyShutdownInstance); !errors.Is(err, context.Canceled) {
+		t.Errorf("TriggerEventContext: expected context.Canceled, got %v", err)
+	}
+	if _, err := client.EventsContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("EventsContext: expected context.Canceled, got %v", err)
+	}
+	if _, err := client.EventsSinceContext(ctx, time.Now()); !errors.Is(err, context.Canceled) {
+		t.Errorf("EventsSinceContext: expected context.Canceled, got %v", err)
+	}
+}
+
+func TestQueryEvents(t *testing.T) {
+	var tests = []struct {
+		query    chaosmonkey.EventQuery
+		expected []string
+	}{
+		{chaosmonkey.EventQuery{}, []string{"i-12345678", "i-87654321"}},
+		{chaosmonkey.EventQuery{Order: chaosmonkey.SortOldestFirst}, []string{"i-87654321", "i-12345678"}},
+		{chaosmonkey.EventQuery{Limit: 1}, []string{"i-12345678"}},
+		{chaosmonkey.EventQuery{Group: "Some*"}, []string{"i-12345678"}},
+		{chaosmonkey.EventQuery{Group: "*AutoScalingGroup"}, []string{"i-12345678", "i-87654321"}},
+		{chaosmonkey.EventQuery{Region: "us-east-1"}, []string{"i-87654321"}},
+		{chaosmonkey.EventQuery{Strategies: []chaosmonkey.Strategy{
+			chaosmonkey.StrategyBlockAllNetworkTraffic,
+			chaosmonkey.StrategyBurnCPU,
+		}}, []string{"i-87654321"}},
+		{chaosmonkey.EventQuery{Since: time.Unix(1460116927, 0)}, []string{"i-12345678"}},
+		{chaosmonkey.EventQuery{Until: time.Unix(1460116927, 0)}, []string{"i-87654321"}},
+		{chaosmonkey.EventQuery{Group: "Nope*"}, nil},
+	}
+
+	for i, tt := range tests {
+		events, err := client.QueryEvents(context.Background(), tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, e := range events {
+			ids = append(ids, e.InstanceID)
+		}
+		if diff := cmp.Diff(tt.expected, ids); diff != "" {
+			t.Errorf("%d: %s", i, diff)
+		}
+	}
+
+	if _, err := client.QueryEvents(context.Background(), chaosmonkey.EventQuery{Group: "["}); err == nil {
+		t.Error("expected error for invalid glob pattern")
+	}
+}
@@ -0,0 +1,308 @@
+/*
+Package chaosmonkeytest provides a fake Chaos Monkey API server for testing.
+
+The server implements the Chaos Monkey REST API in-process. It records chaos
+events triggered via the API, which can then be retrieved via the API or
+inspected directly:
+
+	s := chaosmonkeytest.NewServer(&chaosmonkeytest.Config{
+		Groups: []string{"ExampleAutoScalingGroup"},
+	})
+	defer s.Close()
+
+	client, err := chaosmonkey.NewClient(&chaosmonkey.Config{
+		Endpoint: s.URL,
+	})
+	...
+
+The server can also simulate common failures like a leashed Chaos Monkey,
+slow responses, or server errors.
+*/
+package chaosmonkeytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+)
+
+// Config is used to configure the creation of the server.
+type Config struct {
+	// Optional username and password required for HTTP Basic
+	// Authentication
+	Username string
+	Password string
+
+	// Names of existing auto scaling groups (any name is accepted if empty)
+	Groups []string
+
+	// AWS region of events if not given in the request ("us-east-1" by
+	// default)
+	Region string
+
+	// Whether Chaos Monkey is leashed and refuses to trigger events
+	Leashed bool
+
+	// Whether on-demand termination is disabled
+	OnDemandDisabled bool
+
+	// Time to wait before responding to a request
+	Latency time.Duration
+}
+
+// Server is a fake Chaos Monkey API server. Create a server with NewServer.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	config   Config
+	events   []chaosmonkey.APIResponse
+	nextID   int
+	failures int
+	failCode int
+	requests int
+	groupSet map[string]bool
+}
+
+// NewServer starts and returns a new server for the given configuration,
+// which may be nil. The caller should call Close when finished, to shut it
+// down.
+func NewServer(c *Config) *Server {
+	s := &Server{}
+	if c != nil {
+		s.config = *c
+	}
+	if s.config.Region == "" {
+		s.config.Region = "us-east-1"
+	}
+	s.setGroups(s.config.Groups)
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// SetLeashed sets whether Chaos Monkey is leashed.
+func (s *Server) SetLeashed(leashed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config.Leashed = leashed
+}
+
+// SetOnDemandDisabled sets whether on-demand termination is disabled.
+func (s *Server) SetOnDemandDisabled(disabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config.OnDemandDisabled = disabled
+}
+
+// SetLatency sets the time to wait before responding to a request.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config.Latency = d
+}
+
+// SetGroups sets the names of existing auto scaling groups.
+func (s *Server) SetGroups(groups ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setGroups(groups)
+}
+
+func (s *Server) setGroups(groups []string) {
+	s.groupSet = nil
+	if len(groups) > 0 {
+		s.groupSet = make(map[string]bool)
+		for _, g := range groups {
+			s.groupSet[g] = true
+		}
+	}
+}
+
+// FailNext causes the next n requests to fail with the given HTTP status code.
+func (s *Server) FailNext(n, statusCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = n
+	s.failCode = statusCode
+}
+
+// AddEvent records a chaos event as if it had been triggered via the API.
+func (s *Server) AddEvent(e chaosmonkey.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, chaosmonkey.APIResponse{
+		ChaosType:  string(e.Strategy),
+		EventID:    e.InstanceID,
+		EventTime:  e.TriggeredAt.UnixNano() / int64(time.Millisecond),
+		EventType:  "CHAOS_TERMINATION",
+		GroupName:  e.AutoScalingGroupName,
+		GroupType:  "ASG",
+		MonkeyType: "CHAOS",
+		Region:     e.Region,
+	})
+}
+
+// Events returns all recorded chaos events in the order they were triggered.
+func (s *Server) Events() []chaosmonkey.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var events []chaosmonkey.Event
+	for _, r := range s.events {
+		events = append(events, *r.ToEvent())
+	}
+	return events
+}
+
+// Requests returns the number of requests received by the server.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	config := s.config
+	fail := s.failures > 0
+	if fail {
+		s.failures--
+	}
+	s.mu.Unlock()
+
+	if config.Latency > 0 {
+		select {
+		case <-time.After(config.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if fail {
+		writeError(w, s.failCode, "simulated server error")
+		return
+	}
+
+	if config.Username != "" || config.Password != "" {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != config.Username || pass != config.Password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="simianarmy"`)
+			writeError(w, http.StatusUnauthorized, "")
+			return
+		}
+	}
+
+	if r.URL.Path != chaosmonkey.APIPath {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		s.listEvents(w, r)
+	case "POST":
+		s.triggerEvent(w, r, config)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "")
+	}
+}
+
+func (s *Server) listEvents(w http.ResponseWriter, r *http.Request) {
+	var since int64
+	if v := r.URL.Query().Get("since"); v != "" {
+		var err error
+		if since, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid value for since: %s", v))
+			return
+		}
+	}
+
+	s.mu.Lock()
+	events := []chaosmonkey.APIResponse{}
+	for i := len(s.events) - 1; i >= 0; i-- {
+		if s.events[i].EventTime > since {
+			events = append(events, s.events[i])
+		}
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, events)
+}
+
+func (s *Server) triggerEvent(w http.ResponseWriter, r *http.Request, config Config) {
+	var req chaosmonkey.APIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %s", err))
+		return
+	}
+	if req.EventType != "CHAOS_TERMINATION" {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Unsupported event type: %s", req.EventType))
+		return
+	}
+	if req.GroupType != "ASG" {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Unsupported group type: %s", req.GroupType))
+		return
+	}
+	if req.GroupName == "" {
+		writeError(w, http.StatusBadRequest, "groupName must be specified")
+		return
+	}
+	if config.Leashed {
+		writeError(w, http.StatusInternalServerError,
+			fmt.Sprintf("ChaosMonkey is leashed, no instance in group %s was terminated", req.GroupName))
+		return
+	}
+	if config.OnDemandDisabled {
+		writeError(w, http.StatusForbidden,
+			fmt.Sprintf("Group %s of type %s is not enabled for on-demand termination", req.GroupName, req.GroupType))
+		return
+	}
+
+	s.mu.Lock()
+	if s.groupSet != nil && !s.groupSet[req.GroupName] {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound,
+			fmt.Sprintf("Instance group named '%s' [type %s] cannot be found.", req.GroupName, req.GroupType))
+		return
+	}
+	if req.ChaosType == "" {
+		req.ChaosType = string(chaosmonkey.StrategyShutdownInstance)
+	}
+	if req.Region == "" {
+		req.Region = config.Region
+	}
+	s.nextID++
+	resp := chaosmonkey.APIResponse{
+		ChaosType:  req.ChaosType,
+		EventID:    fmt.Sprintf("i-%017x", s.nextID),
+		EventTime:  time.Now().UnixNano() / int64(time.Millisecond),
+		EventType:  req.EventType,
+		GroupName:  req.GroupName,
+		GroupType:  req.GroupType,
+		MonkeyType: "CHAOS",
+		Region:     req.Region,
+	}
+	s.events = append(s.events, resp)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+	writeJSON(w, statusCode, chaosmonkey.APIResponse{Message: message})
+}
@@ -0,0 +1,126 @@
+package chaosmonkeytest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+	"github.com/mlafeldt/chaosmonkey/lib/chaosmonkeytest"
+)
+
+func newClient(t *testing.T, s *chaosmonkeytest.Server) *chaosmonkey.Client {
+	client, err := chaosmonkey.NewClient(&chaosmonkey.Config{
+		Endpoint: s.URL,
+		Username: "user",
+		Password: "secret",
+		RetryPolicy: &chaosmonkey.RetryPolicy{
+			MaxAttempts: 1,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestServer(t *testing.T) {
+	s := chaosmonkeytest.NewServer(&chaosmonkeytest.Config{
+		Username: "user",
+		Password: "secret",
+		Groups:   []string{"SomeAutoScalingGroup"},
+		Region:   "eu-west-1",
+	})
+	defer s.Close()
+	client := newClient(t, s)
+
+	start := time.Now().Add(-time.Second)
+	event, err := client.TriggerEvent("SomeAutoScalingGroup", chaosmonkey.StrategyBurnCPU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.InstanceID == "" || event.AutoScalingGroupName != "SomeAutoScalingGroup" ||
+		event.Region != "eu-west-1" || event.Strategy != chaosmonkey.StrategyBurnCPU ||
+		event.TriggeredAt.Before(start) {
+		t.Fatalf("unexpected event %+v", event)
+	}
+
+	events, err := client.Events()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0] != *event {
+		t.Fatalf("unexpected events %+v", events)
+	}
+
+	events, err = client.EventsSince(time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("expected no events, got %+v", events)
+	}
+
+	if len(s.Events()) != 1 {
+		t.Fatalf("expected 1 recorded event, got %+v", s.Events())
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	s := chaosmonkeytest.NewServer(&chaosmonkeytest.Config{
+		Username: "user",
+		Password: "secret",
+		Groups:   []string{"SomeAutoScalingGroup"},
+	})
+	defer s.Close()
+	client := newClient(t, s)
+
+	trigger := func(group string) error {
+		_, err := client.TriggerEvent(group, chaosmonkey.StrategyShutdownInstance)
+		return err
+	}
+
+	if err := trigger("UnknownGroup"); !errors.Is(err, chaosmonkey.ErrGroupNotFound) {
+		t.Errorf("expected ErrGroupNotFound, got %v", err)
+	}
+
+	s.SetLeashed(true)
+	if err := trigger("SomeAutoScalingGroup"); !errors.Is(err, chaosmonkey.ErrMonkeyLeashed) {
+		t.Errorf("expected ErrMonkeyLeashed, got %v", err)
+	}
+	s.SetLeashed(false)
+
+	s.SetOnDemandDisabled(true)
+	if err := trigger("SomeAutoScalingGroup"); !errors.Is(err, chaosmonkey.ErrOnDemandDisabled) {
+		t.Errorf("expected ErrOnDemandDisabled, got %v", err)
+	}
+	s.SetOnDemandDisabled(false)
+
+	s.FailNext(1, http.StatusServiceUnavailable)
+	var apiErr *chaosmonkey.APIError
+	if err := trigger("SomeAutoScalingGroup"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 error, got %v", err)
+	}
+
+	s.SetLatency(time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.EventsContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	s.SetLatency(0)
+
+	unauthorized, err := chaosmonkey.NewClient(&chaosmonkey.Config{Endpoint: s.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unauthorized.Events(); !errors.Is(err, chaosmonkey.ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized, got %v", err)
+	}
+
+	if len(s.Events()) != 0 {
+		t.Errorf("expected no recorded events, got %+v", s.Events())
+	}
+}
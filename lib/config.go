@@ -0,0 +1,238 @@
+package chaosmonkey
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/mlafeldt/chaosmonkey/internal/xdg"
+)
+
+// ConfigFile is the content of a configuration file defining named profiles,
+// e.g.:
+//
+//	default-profile: staging
+//	profiles:
+//	  staging:
+//	    endpoint: http://chaosmonkey.staging.example.com:8080
+//	  prod-eu:
+//	    endpoint: https://chaosmonkey.prod.example.com
+//	    region: eu-west-1
+//	    username: admin
+//	    password-env: PROD_CHAOSMONKEY_PASSWORD
+//	    timeout: 30s
+//	  prod-us:
+//	    endpoint: https://chaosmonkey.us.example.com
+//	    token-command: get-token chaosmonkey
+//	    limits:
+//	      min-in-service: 50%
+//	      max-count: 3
+//	strategies:
+//	  - name: KillNginx
+//	    description: Kill nginx processes
+//	    requires-ssh: true
+type ConfigFile struct {
+	// Profile used if none is selected explicitly
+	DefaultProfile string `yaml:"default-profile"`
+
+	Profiles map[string]Profile `yaml:"profiles"`
+
+	// Custom chaos strategies to register, see RegisterStrategy
+	Strategies []StrategyInfo `yaml:"strategies"`
+}
+
+// Profile describes a Chaos Monkey installation and how to use it.
+type Profile struct {
+	// Address and port of the Chaos Monkey API server
+	Endpoint string `yaml:"endpoint"`
+
+	// AWS region (ignored by vanilla Chaos Monkey)
+	Region string `yaml:"region"`
+
+	// Credentials for HTTP Basic Authentication. Instead of storing the
+	// password in the file, PasswordEnv may reference an environment
+	// variable holding it, PasswordFile a file containing it, and
+	// PasswordCommand a credential helper printing it.
+	Username        string `yaml:"username"`
+	Password        string `yaml:"password"`
+	PasswordEnv     string `yaml:"password-env"`
+	PasswordFile    string `yaml:"password-file"`
+	PasswordCommand string `yaml:"password-command"`
+
+	// Authentication other than HTTP Basic Authentication
+	AuthOptions `yaml:",inline"`
+
+	// Custom HTTP User Agent
+	UserAgent string `yaml:"user-agent"`
+
+	// Timeout of HTTP requests (no timeout by default)
+	Timeout time.Duration `yaml:"timeout"`
+
+	// Default safety limits applied when triggering chaos events
+	Limits Limits `yaml:"limits"`
+}
+
+// Limits are safety limits for triggering chaos events. They are not enforced
+// by the client, but by tools built on top of it, such as the CLI.
+type Limits struct {
+	// Minimum number or percentage of instances in service each auto
+	// scaling group must keep, e.g. "2" or "50%"
+	MinInService string `yaml:"min-in-service"`
+
+	// Time to wait for an auto scaling group to regain capacity
+	CapacityWait time.Duration `yaml:"capacity-wait"`
+
+	// Time to wait for an auto scaling group to recover after a chaos event
+	RecoveryTimeout time.Duration `yaml:"recovery-timeout"`
+
+	// Maximum number of chaos events triggered at once in a group
+	MaxCount int `yaml:"max-count"`
+}
+
+// DefaultConfigFile returns the path of the configuration file, which is
+// either set via the environment variable CHAOSMONKEY_CONFIG or located in the
+// user's configuration directory, i.e. ~/.config/chaosmonkey/config.yaml.
+func DefaultConfigFile() string {
+	if v := os.Getenv("CHAOSMONKEY_CONFIG"); v != "" {
+		return v
+	}
+	return xdg.ConfigFile("config.yaml")
+}
+
+// ReadConfigFile reads the configuration file with the given name.
+func ReadConfigFile(filename string) (*ConfigFile, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var f ConfigFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return &f, nil
+}
+
+// Profile returns the profile with the given name, or the default profile if
+// name is empty. It returns nil if name is empty and there is no default
+// profile.
+func (f *ConfigFile) Profile(name string) (*Profile, error) {
+	if name == "" {
+		name = f.DefaultProfile
+		if name == "" {
+			return nil, nil
+		}
+	}
+	p, ok := f.Profiles[name]
+	if !ok {
+		var names []string
+		for n := range f.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("profile %q does not exist (available profiles: %s)",
+			name, strings.Join(names, ", "))
+	}
+	return &p, nil
+}
+
+// RegisterStrategies registers the custom strategies of the file.
+func (f *ConfigFile) RegisterStrategies() error {
+	for _, s := range f.Strategies {
+		if err := RegisterStrategy(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadProfile returns the profile with the given name from the default
+// configuration file. If name is empty, the profile named by the environment
+// variable CHAOSMONKEY_PROFILE is used, or else the default profile of the
+// file. It returns nil if no profile is selected, including when there is no
+// configuration file.
+func LoadProfile(name string) (*Profile, error) {
+	if name == "" {
+		name = os.Getenv("CHAOSMONKEY_PROFILE")
+	}
+	f, err := ReadConfigFile(DefaultConfigFile())
+	if os.IsNotExist(err) && name == "" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f.Profile(name)
+}
+
+// Config returns a client configuration for the profile, based on the default
+// configuration.
+func (p *Profile) Config() (*Config, error) {
+	c := DefaultConfig()
+	if p.Endpoint != "" {
+		c.Endpoint = p.Endpoint
+	}
+	if p.Region != "" {
+		c.Region = p.Region
+	}
+	if p.Username != "" {
+		c.Username = p.Username
+	}
+	if p.Password != "" {
+		c.Password = p.Password
+	}
+	if p.PasswordEnv != "" {
+		v := os.Getenv(p.PasswordEnv)
+		if v == "" {
+			return nil, fmt.Errorf("environment variable %s referenced by profile is not set", p.PasswordEnv)
+		}
+		c.Password = v
+	}
+	if p.PasswordFile != "" || p.PasswordCommand != "" {
+		c.PasswordFile, c.PasswordCommand = p.PasswordFile, p.PasswordCommand
+	}
+	a, err := p.Authenticator()
+	if err != nil {
+		return nil, err
+	}
+	c.Authenticator = a
+	tls, err := p.TLS()
+	if err != nil {
+		return nil, err
+	}
+	if tls != nil {
+		c.TLS = tls
+	}
+	if p.UserAgent != "" {
+		c.UserAgent = p.UserAgent
+	}
+	if p.Timeout > 0 {
+		c.HTTPClient = &http.Client{Timeout: p.Timeout}
+	}
+	return c, nil
+}
+
+// LoadConfig returns the client configuration for the given profile, as
+// selected by LoadProfile. The environment variables CHAOSMONKEY_ENDPOINT,
+// CHAOSMONKEY_USERNAME, and CHAOSMONKEY_PASSWORD take precedence over the
+// profile. Without a profile, LoadConfig is equivalent to DefaultConfig.
+func LoadConfig(profile string) (*Config, error) {
+	p, err := LoadProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return DefaultConfig(), nil
+	}
+	c, err := p.Config()
+	if err != nil {
+		return nil, err
+	}
+	c.readEnv()
+	return c, nil
+}
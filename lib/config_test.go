@@ -0,0 +1,152 @@
+package chaosmonkey_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+)
+
+const exampleConfigFile = `
+default-profile: staging
+profiles:
+  staging:
+    endpoint: http://staging.example.com:8080
+  prod-eu:
+    endpoint: https://prod.example.com
+    region: eu-west-1
+    username: admin
+    password-env: TEST_CHAOSMONKEY_PASSWORD
+    user-agent: gameday
+    timeout: 30s
+    limits:
+      min-in-service: 50%
+      capacity-wait: 5m
+      max-count: 3
+`
+
+func withConfigFile(t *testing.T, content string) func() {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("CHAOSMONKEY_CONFIG", path)
+	return func() {
+		os.Unsetenv("CHAOSMONKEY_CONFIG")
+		os.RemoveAll(dir)
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	defer withConfigFile(t, exampleConfigFile)()
+
+	p, err := chaosmonkey.LoadProfile("prod-eu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &chaosmonkey.Profile{
+		Endpoint:    "https://prod.example.com",
+		Region:      "eu-west-1",
+		Username:    "admin",
+		PasswordEnv: "TEST_CHAOSMONKEY_PASSWORD",
+		UserAgent:   "gameday",
+		Timeout:     30 * time.Second,
+		Limits: chaosmonkey.Limits{
+			MinInService: "50%",
+			CapacityWait: 5 * time.Minute,
+			MaxCount:     3,
+		},
+	}
+	if diff := cmp.Diff(want, p); diff != "" {
+		t.Fatal(diff)
+	}
+
+	// Default profile
+	if p, err = chaosmonkey.LoadProfile(""); err != nil {
+		t.Fatal(err)
+	}
+	if p.Endpoint != "http://staging.example.com:8080" {
+		t.Errorf("unexpected default profile %+v", p)
+	}
+
+	// Profile selected via environment
+	os.Setenv("CHAOSMONKEY_PROFILE", "prod-eu")
+	defer os.Unsetenv("CHAOSMONKEY_PROFILE")
+	if p, err = chaosmonkey.LoadProfile(""); err != nil {
+		t.Fatal(err)
+	}
+	if p.Endpoint != "https://prod.example.com" {
+		t.Errorf("unexpected profile from environment %+v", p)
+	}
+
+	if _, err := chaosmonkey.LoadProfile("prod-us"); err == nil || !strings.Contains(err.Error(), "available profiles: prod-eu, staging") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	defer withConfigFile(t, exampleConfigFile)()
+
+	if _, err := chaosmonkey.LoadConfig("prod-eu"); err == nil {
+		t.Error("expected error for unset password environment variable")
+	}
+
+	os.Setenv("TEST_CHAOSMONKEY_PASSWORD", "secret")
+	defer os.Unsetenv("TEST_CHAOSMONKEY_PASSWORD")
+	c, err := chaosmonkey.LoadConfig("prod-eu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Endpoint != "https://prod.example.com" || c.Region != "eu-west-1" ||
+		c.Username != "admin" || c.Password != "secret" || c.UserAgent != "gameday" {
+		t.Errorf("unexpected config %+v", c)
+	}
+	if c.HTTPClient == http.DefaultClient || c.HTTPClient.Timeout != 30*time.Second {
+		t.Errorf("unexpected HTTP client %+v", c.HTTPClient)
+	}
+
+	// Environment takes precedence over profile
+	os.Setenv("CHAOSMONKEY_ENDPOINT", "http://localhost:8080")
+	defer os.Unsetenv("CHAOSMONKEY_ENDPOINT")
+	if c, err = chaosmonkey.LoadConfig("prod-eu"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Endpoint != "http://localhost:8080" {
+		t.Errorf("want endpoint from environment, got %q", c.Endpoint)
+	}
+}
+
+func TestLoadConfigWithoutFile(t *testing.T) {
+	os.Setenv("CHAOSMONKEY_CONFIG", filepath.Join(os.TempDir(), "does-not-exist.yaml"))
+	defer os.Unsetenv("CHAOSMONKEY_CONFIG")
+
+	c, err := chaosmonkey.LoadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(chaosmonkey.DefaultConfig().Endpoint, c.Endpoint); diff != "" {
+		t.Error(diff)
+	}
+	if _, err := chaosmonkey.LoadConfig("staging"); !os.IsNotExist(err) {
+		t.Errorf("expected error for missing config file, got %v", err)
+	}
+}
+
+func TestReadConfigFileInvalid(t *testing.T) {
+	defer withConfigFile(t, "profiles:\n  staging:\n    endpont: http://example.com\n")()
+
+	if _, err := chaosmonkey.LoadProfile("staging"); err == nil || !strings.Contains(err.Error(), "endpont") {
+		t.Errorf("expected error for unknown field, got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package chaosmonkey
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// These are the errors recognized from responses of the Chaos Monkey API.
+// Use errors.Is to check whether an error returned by the client is one of
+// them.
+var (
+	// ErrUnauthorized is returned if the API server rejected the
+	// credentials used for HTTP Basic Authentication.
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrMonkeyLeashed is returned if Chaos Monkey is leashed, in which
+	// case it does not terminate any instances.
+	ErrMonkeyLeashed = errors.New("chaos monkey is leashed")
+
+	// ErrOnDemandDisabled is returned if on-demand termination is not
+	// enabled for the auto scaling group.
+	ErrOnDemandDisabled = errors.New("on-demand termination is not enabled")
+
+	// ErrGroupNotFound is returned if the auto scaling group does not exist.
+	ErrGroupNotFound = errors.New("auto scaling group not found")
+
+	// ErrNoInstance is returned if there is no instance in the auto scaling
+	// group that could be terminated.
+	ErrNoInstance = errors.New("no instance found in auto scaling group")
+)
+
+// APIError describes an unsuccessful response returned by the API.
+type APIError struct {
+	// HTTP method and URL of the failed request
+	Method string
+	URL    string
+
+	// HTTP status code and status line of the response
+	StatusCode int
+	Status     string
+
+	// Raw response body
+	Body []byte
+
+	// Message decoded from the response body, if any
+	Message string
+
+	// Delay requested by the server via the Retry-After header, if any
+	RetryAfter time.Duration
+
+	// One of the Err* errors, or nil if the error is not known
+	Err error
+}
+
+// Error returns the message sent by the API server, or the HTTP status if the
+// server did not send a message.
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("HTTP error: %s", e.Status)
+}
+
+// Unwrap returns the known error the APIError corresponds to, which makes the
+// APIError usable with errors.Is.
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Messages sent by Simian Army and the errors they correspond to
+var knownMessages = []struct {
+	substr string
+	err    error
+}{
+	{"leashed", ErrMonkeyLeashed},
+	{"not enabled for on-demand termination", ErrOnDemandDisabled},
+	{"cannot be found", ErrGroupNotFound},
+	{"no instance is found", ErrNoInstance},
+}
+
+func decodeError(resp *http.Response) error {
+	e := &APIError{
+		Method:     resp.Request.Method,
+		URL:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err == nil {
+		e.Body = body
+		var r APIResponse
+		if json.Unmarshal(body, &r) == nil {
+			e.Message = r.Message
+		}
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		e.Err = ErrUnauthorized
+		return e
+	}
+	msg := strings.ToLower(e.Message)
+	for _, m := range knownMessages {
+		if strings.Contains(msg, m.substr) {
+			e.Err = m.err
+			break
+		}
+	}
+	return e
+}
@@ -0,0 +1,62 @@
+package chaosmonkey_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+)
+
+func TestAPIError(t *testing.T) {
+	var tests = []struct {
+		status  int
+		body    string
+		err     error
+		message string
+	}{
+		{http.StatusUnauthorized, ``, chaosmonkey.ErrUnauthorized, "HTTP error: 401 Unauthorized"},
+		{http.StatusForbidden, `{"message": "Group SomeAutoScalingGroup of type ASG is not enabled for on-demand termination"}`,
+			chaosmonkey.ErrOnDemandDisabled, "Group SomeAutoScalingGroup of type ASG is not enabled for on-demand termination"},
+		{http.StatusNotFound, `{"message": "Instance group named 'SomeAutoScalingGroup' [type ASG] cannot be found."}`,
+			chaosmonkey.ErrGroupNotFound, "Instance group named 'SomeAutoScalingGroup' [type ASG] cannot be found."},
+		{http.StatusGone, `{"message": "No instance is found in group SomeAutoScalingGroup [type ASG]"}`,
+			chaosmonkey.ErrNoInstance, "No instance is found in group SomeAutoScalingGroup [type ASG]"},
+		{http.StatusInternalServerError, `{"message": "ChaosMonkey is leashed"}`,
+			chaosmonkey.ErrMonkeyLeashed, "ChaosMonkey is leashed"},
+		{http.StatusInternalServerError, `oops`, nil, "HTTP error: 500 Internal Server Error"},
+	}
+
+	for _, tt := range tests {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			fmt.Fprint(w, tt.body)
+		}))
+
+		c, err := chaosmonkey.NewClient(&chaosmonkey.Config{Endpoint: ts.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = c.TriggerEvent("SomeAutoScalingGroup", chaosmonkey.StrategyShutdownInstance)
+		ts.Close()
+
+		var apiErr *chaosmonkey.APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("%d: expected *APIError, got %T", tt.status, err)
+		}
+		if apiErr.StatusCode != tt.status || apiErr.Method != "POST" || string(apiErr.Body) != tt.body {
+			t.Errorf("%d: unexpected APIError %+v", tt.status, apiErr)
+		}
+		if tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("%d: expected %v, got %v", tt.status, tt.err, err)
+		}
+		if tt.err == nil && apiErr.Err != nil {
+			t.Errorf("%d: expected no known error, got %v", tt.status, apiErr.Err)
+		}
+		if err.Error() != tt.message {
+			t.Errorf("%d: expected message %q, got %q", tt.status, tt.message, err.Error())
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package chaosmonkey
+
+import "strings"
+
+// UnregisterStrategy removes a custom strategy registered by a test, so that
+// the test can be run repeatedly.
+func UnregisterStrategy(s Strategy) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	for i, info := range strategies {
+		if info.Custom && strings.EqualFold(string(info.Strategy), string(s)) {
+			strategies = append(strategies[:i], strategies[i+1:]...)
+			return
+		}
+	}
+}
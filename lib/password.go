@@ -0,0 +1,205 @@
+package chaosmonkey
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ResolvePassword sets the password, if it is empty, from the first of these
+// sources that is configured:
+//
+//   - PasswordFile, which is read from stdin if set to "-"
+//   - PasswordCommand, a credential helper printing the password to stdout
+//   - NetrcFile, using the entry of the endpoint's host
+//
+// The sources are cleared afterwards, so that they are consulted only once.
+// ResolvePassword is called by NewClient.
+func (c *Config) ResolvePassword() error {
+	defer func() {
+		c.PasswordFile, c.PasswordCommand, c.NetrcFile = "", "", ""
+	}()
+	if c.Password != "" {
+		return nil
+	}
+
+	var err error
+	switch {
+	case c.PasswordFile == "-":
+		c.Password, err = readPassword(os.Stdin)
+	case c.PasswordFile != "":
+		c.Password, err = ReadPasswordFile(c.PasswordFile)
+	case c.PasswordCommand != "":
+		c.Password, err = PasswordFromCommand(c.PasswordCommand)
+	case c.NetrcFile != "":
+		var login, password string
+		login, password, err = NetrcCredentials(c.NetrcFile, endpointHost(c.Endpoint))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if login != "" && (c.Username == "" || c.Username == login) {
+			c.Username, c.Password = login, password
+		}
+	}
+	return err
+}
+
+// ReadPasswordFile returns the password stored in the given file, without
+// trailing newline.
+func ReadPasswordFile(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return readPassword(f)
+}
+
+// readPassword returns the first line read from r.
+func readPassword(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("password is empty")
+	}
+	return password, nil
+}
+
+// PasswordFromCommand runs the given credential helper command via "sh -c" and
+// returns the first line of its output as password.
+func PasswordFromCommand(command string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("password command failed: %s: %s", err, msg)
+		}
+		return "", fmt.Errorf("password command failed: %s", err)
+	}
+	return readPassword(bytes.NewReader(out))
+}
+
+// DefaultNetrcFile returns the path of the netrc file, which is either set via
+// the environment variable NETRC or ~/.netrc.
+func DefaultNetrcFile() string {
+	if v := os.Getenv("NETRC"); v != "" {
+		return v
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// NetrcCredentials returns the login and password for the given host from the
+// netrc file with the given name. If there is no entry for the host, the
+// default entry is used, if any. Empty strings are returned if neither exists.
+func NetrcCredentials(filename, host string) (login, password string, err error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", "", err
+	}
+
+	type entry struct{ machine, login, password string }
+	var (
+		entries []*entry
+		current *entry
+	)
+	tokens := netrcTokens(data)
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "machine", "default":
+			current = &entry{}
+			if tokens[i] == "machine" {
+				if i+1 >= len(tokens) {
+					return "", "", fmt.Errorf("%s: missing name after machine", filename)
+				}
+				i++
+				current.machine = tokens[i]
+			}
+			entries = append(entries, current)
+		case "login", "password", "account":
+			if i+1 >= len(tokens) {
+				return "", "", fmt.Errorf("%s: missing value after %s", filename, tokens[i])
+			}
+			i++
+			if current == nil {
+				continue
+			}
+			if tokens[i-1] == "login" {
+				current.login = tokens[i]
+			} else if tokens[i-1] == "password" {
+				current.password = tokens[i]
+			}
+		}
+	}
+
+	// The first matching machine wins, otherwise the default entry is used
+	var def *entry
+	for _, e := range entries {
+		if e.machine == host {
+			return e.login, e.password, nil
+		}
+		if e.machine == "" && def == nil {
+			def = e
+		}
+	}
+	if def != nil {
+		return def.login, def.password, nil
+	}
+	return "", "", nil
+}
+
+// netrcTokens splits the content of a netrc file into tokens, skipping macro
+// definitions and comments.
+func netrcTokens(data []byte) []string {
+	var tokens []string
+	inMacro := false
+	for _, line := range strings.Split(string(data), "\n") {
+		if inMacro {
+			if strings.TrimSpace(line) == "" {
+				inMacro = false
+			}
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		for i, f := range fields {
+			if f == "macdef" {
+				inMacro = true
+				fields = fields[:i]
+				break
+			}
+		}
+		tokens = append(tokens, fields...)
+	}
+	return tokens
+}
+
+// endpointHost returns the host name of the endpoint, without port.
+func endpointHost(endpoint string) string {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
@@ -0,0 +1,121 @@
+package chaosmonkey_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+	"github.com/mlafeldt/chaosmonkey/lib/chaosmonkeytest"
+)
+
+const exampleNetrc = `
+# Chaos Monkey installations
+machine staging.example.com login admin password staging-secret
+machine 127.0.0.1
+	login monkey
+	password netrc-secret
+macdef init
+	cd /pub
+	password ignored
+
+default login anonymous password guest
+`
+
+func tempFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNetrcCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	netrc := tempFile(t, dir, "netrc", exampleNetrc)
+
+	var tests = []struct {
+		host            string
+		login, password string
+	}{
+		{"staging.example.com", "admin", "staging-secret"},
+		{"127.0.0.1", "monkey", "netrc-secret"},
+		{"prod.example.com", "anonymous", "guest"},
+	}
+	for _, tt := range tests {
+		login, password, err := chaosmonkey.NetrcCredentials(netrc, tt.host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if login != tt.login || password != tt.password {
+			t.Errorf("%s: want %s/%s, got %s/%s", tt.host, tt.login, tt.password, login, password)
+		}
+	}
+
+	if _, _, err := chaosmonkey.NetrcCredentials(tempFile(t, dir, "bad", "machine"), "x"); err == nil {
+		t.Error("expected error for malformed netrc file")
+	}
+}
+
+func TestPasswordSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := chaosmonkeytest.NewServer(&chaosmonkeytest.Config{
+		Username: "monkey",
+		Password: "netrc-secret",
+		Groups:   []string{"ExampleAutoScalingGroup"},
+	})
+	defer s.Close()
+
+	var tests = []struct {
+		name   string
+		config chaosmonkey.Config
+	}{
+		{"file", chaosmonkey.Config{
+			Username:     "monkey",
+			PasswordFile: tempFile(t, dir, "password", "netrc-secret\n"),
+		}},
+		{"command", chaosmonkey.Config{
+			Username:        "monkey",
+			PasswordCommand: "echo netrc-secret",
+		}},
+		{"netrc", chaosmonkey.Config{
+			NetrcFile: tempFile(t, dir, "netrc", exampleNetrc),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.Endpoint = s.URL
+			client, err := chaosmonkey.NewClient(&config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Password != "netrc-secret" {
+				t.Errorf("password not resolved: %q", config.Password)
+			}
+			if _, err := client.TriggerEvent("ExampleAutoScalingGroup", chaosmonkey.StrategyShutdownInstance); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	_, err = chaosmonkey.NewClient(&chaosmonkey.Config{PasswordCommand: "echo oops >&2; exit 1"})
+	if err == nil || !strings.Contains(err.Error(), "oops") {
+		t.Errorf("expected error of password command, got %v", err)
+	}
+	_, err = chaosmonkey.NewClient(&chaosmonkey.Config{PasswordFile: tempFile(t, dir, "empty", "")})
+	if err == nil {
+		t.Error("expected error for empty password file")
+	}
+}
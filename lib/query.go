@@ -0,0 +1,120 @@
+package chaosmonkey
+
+import (
+	"context"
+	"path"
+	"sort"
+	"time"
+)
+
+// SortOrder defines the order of events returned by QueryEvents.
+type SortOrder int
+
+// These are the supported sort orders.
+const (
+	// SortNewestFirst sorts events by descending time (the default).
+	SortNewestFirst SortOrder = iota
+
+	// SortOldestFirst sorts events by ascending time.
+	SortOldestFirst
+)
+
+// EventQuery describes which chaos events to return by QueryEvents. The zero
+// value matches all events.
+type EventQuery struct {
+	// Optional glob pattern the name of the auto scaling group must match,
+	// using the syntax of path.Match
+	Group string
+
+	// Optional AWS region of the events
+	Region string
+
+	// Optional list of chaos strategies, one of which the events must have
+	// used
+	Strategies []Strategy
+
+	// Optional time window of the events, including Since but excluding
+	// Until
+	Since time.Time
+	Until time.Time
+
+	// Maximum number of events to return (no limit if zero)
+	Limit int
+
+	// Order in which to return events
+	Order SortOrder
+}
+
+// Match reports whether the given event matches the query. Limit and Order
+// are ignored.
+func (q *EventQuery) Match(e *Event) bool {
+	if q.Group != "" {
+		if ok, err := path.Match(q.Group, e.AutoScalingGroupName); err != nil || !ok {
+			return false
+		}
+	}
+	if q.Region != "" && q.Region != e.Region {
+		return false
+	}
+	if len(q.Strategies) > 0 {
+		found := false
+		for _, s := range q.Strategies {
+			if s == e.Strategy {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !q.Since.IsZero() && e.TriggeredAt.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !e.TriggeredAt.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// QueryEvents returns a list of chaos events matching the given query. Events
+// are filtered by time on the server and by everything else on the client.
+func (c *Client) QueryEvents(ctx context.Context, q EventQuery) ([]Event, error) {
+	if q.Group != "" {
+		if _, err := path.Match(q.Group, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	var since int64
+	if !q.Since.IsZero() {
+		// The API returns events after the given time, so subtract one
+		// millisecond to include events triggered exactly at that time
+		since = q.Since.UTC().UnixNano()/int64(time.Millisecond) - 1
+	}
+
+	all, err := c.events(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	for i := range all {
+		if q.Match(&all[i]) {
+			events = append(events, all[i])
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if q.Order == SortOldestFirst {
+			return events[i].TriggeredAt.Before(events[j].TriggeredAt)
+		}
+		return events[j].TriggeredAt.Before(events[i].TriggeredAt)
+	})
+
+	if q.Limit > 0 && len(events) > q.Limit {
+		events = events[:q.Limit]
+	}
+
+	return events, nil
+}
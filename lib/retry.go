@@ -0,0 +1,147 @@
+package chaosmonkey
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures how the client retries failed requests. Requests
+// are retried on network errors and on responses with a retryable status code,
+// but not if they were canceled or failed otherwise, e.g. to authenticate.
+type RetryPolicy struct {
+	// Maximum number of attempts per request, including the first one
+	// (a value of 1 or less disables retries)
+	MaxAttempts int
+
+	// Delay before the first retry, doubled with every further attempt
+	MinBackoff time.Duration
+
+	// Upper limit for the delay between two attempts
+	MaxBackoff time.Duration
+
+	// HTTP status codes of responses to retry
+	RetryableStatusCodes []int
+
+	// Also retry requests triggering chaos events. As these requests are
+	// not idempotent, a retry might terminate more instances than
+	// intended, which is why only requests retrieving events are retried
+	// by default.
+	RetryTriggerEvent bool
+
+	// Optional function called before each retry with the number of the
+	// failed attempt, its error, and the delay until the next attempt
+	OnRetry func(attempt int, err error, delay time.Duration)
+}
+
+// DefaultRetryPolicy returns the retry policy used by default.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  500 * time.Millisecond,
+		MaxBackoff:  10 * time.Second,
+		RetryableStatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// retryable reports whether a request that failed with the given error should
+// be retried.
+func (p *RetryPolicy) retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		// Only retry network errors, not e.g. invalid responses
+		var urlErr *url.Error
+		var netErr net.Error
+		return errors.As(err, &urlErr) || errors.As(err, &netErr)
+	}
+	if apiErr.Err != nil {
+		// Known error that won't go away by retrying
+		return false
+	}
+	for _, code := range p.RetryableStatusCodes {
+		if apiErr.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the delay after the given failed attempt, which is an
+// exponential backoff with jitter, or the delay requested by the server if
+// that is longer.
+func (p *RetryPolicy) backoff(attempt int, err error) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d > 0 {
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > d {
+		d = apiErr.RetryAfter
+	}
+	return d
+}
+
+// sendRequestWithRetry calls sendRequest until it succeeds, the error is not
+// retryable, or the maximum number of attempts is reached.
+func (c *Client) sendRequestWithRetry(ctx context.Context, method, url string, body []byte, out interface{}) error {
+	p := c.config.RetryPolicy
+	retry := method == "GET" || p.RetryTriggerEvent
+
+	for attempt := 1; ; attempt++ {
+		err := c.sendRequest(ctx, method, url, body, out)
+		if err == nil || !retry || attempt >= p.MaxAttempts || ctx.Err() != nil || !p.retryable(err) {
+			return err
+		}
+
+		delay := p.backoff(attempt, err)
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, err, delay)
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either a
+// number of seconds or an HTTP date.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,130 @@
+package chaosmonkey_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	var tests = []struct {
+		triggerEvent      bool
+		retryTriggerEvent bool
+		failures          int
+		attempts          int
+		success           bool
+	}{
+		{false, false, 0, 1, true},
+		{false, false, 2, 3, true},
+		{false, false, 3, 3, false},
+		{true, false, 2, 1, false},
+		{true, true, 2, 3, true},
+	}
+
+	for i, tt := range tests {
+		attempts := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			if attempts <= tt.failures {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			if r.Method == "POST" {
+				fmt.Fprint(w, newEvent)
+			} else {
+				fmt.Fprint(w, pastEvents)
+			}
+		}))
+
+		var retries []int
+		c, err := chaosmonkey.NewClient(&chaosmonkey.Config{
+			Endpoint: ts.URL,
+			RetryPolicy: &chaosmonkey.RetryPolicy{
+				MaxAttempts:          3,
+				MinBackoff:           time.Millisecond,
+				MaxBackoff:           5 * time.Millisecond,
+				RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+				RetryTriggerEvent:    tt.retryTriggerEvent,
+				OnRetry: func(attempt int, err error, delay time.Duration) {
+					retries = append(retries, attempt)
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tt.triggerEvent {
+			_, err = c.TriggerEvent("SomeAutoScalingGroup", chaosmonkey.StrategyShutdownInstance)
+		} else {
+			_, err = c.Events()
+		}
+		ts.Close()
+
+		if (err == nil) != tt.success {
+			t.Errorf("%d: expected success %v, got error %v", i, tt.success, err)
+		}
+		if attempts != tt.attempts {
+			t.Errorf("%d: expected %d attempts, got %d", i, tt.attempts, attempts)
+		}
+		if len(retries) != tt.attempts-1 {
+			t.Errorf("%d: expected %d retries, got %v", i, tt.attempts-1, retries)
+		}
+	}
+}
+
+// failingAuth is an Authenticator that always fails.
+type failingAuth struct{}
+
+func (failingAuth) Authenticate(req *http.Request) error {
+	return errors.New("no credentials")
+}
+
+func TestRetryPolicyErrors(t *testing.T) {
+	invalid := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "not JSON")
+	}))
+	defer invalid.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	var tests = []struct {
+		endpoint      string
+		authenticator chaosmonkey.Authenticator
+		retries       int
+	}{
+		{closed.URL, nil, 2},
+		{invalid.URL, nil, 0},
+		{invalid.URL, failingAuth{}, 0},
+	}
+
+	for i, tt := range tests {
+		retries := 0
+		c, err := chaosmonkey.NewClient(&chaosmonkey.Config{
+			Endpoint:      tt.endpoint,
+			Authenticator: tt.authenticator,
+			RetryPolicy: &chaosmonkey.RetryPolicy{
+				MaxAttempts: 3,
+				MinBackoff:  time.Millisecond,
+				OnRetry: func(attempt int, err error, delay time.Duration) {
+					retries++
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Events(); err == nil {
+			t.Errorf("%d: expected error", i)
+		}
+		if retries != tt.retries {
+			t.Errorf("%d: expected %d retries, got %d", i, tt.retries, retries)
+		}
+	}
+}
@@ -1,5 +1,12 @@
 package chaosmonkey
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
 // Strategy defines a chaos strategy for terminating EC2 instances.
 type Strategy string
 
@@ -109,3 +116,200 @@ var Strategies = []Strategy{
 	StrategyNetworkLatency,
 	StrategyNetworkLoss,
 }
+
+// ErrUnknownStrategy is returned for chaos strategies that are not registered.
+var ErrUnknownStrategy = errors.New("unknown chaos strategy")
+
+// StrategyInfo describes a chaos strategy.
+type StrategyInfo struct {
+	// Name of the strategy as sent to Chaos Monkey
+	Strategy Strategy `yaml:"name"`
+
+	// Short description of what the strategy does
+	Description string `yaml:"description"`
+
+	// Whether Chaos Monkey needs SSH access to the instance
+	RequiresSSH bool `yaml:"requires-ssh"`
+
+	// Whether the strategy is a custom one, e.g. a custom chaos script of
+	// Simian Army
+	Custom bool `yaml:"-"`
+
+	// Optional additional information about the strategy
+	Metadata map[string]string `yaml:"metadata"`
+}
+
+var (
+	strategiesMu sync.RWMutex
+	strategies   []StrategyInfo
+)
+
+func init() {
+	descriptions := map[Strategy]string{
+		StrategyShutdownInstance:       "Shut down the instance",
+		StrategyBlockAllNetworkTraffic: "Move the instance into a security group without any access",
+		StrategyDetachVolumes:          "Force-detach all EBS volumes from the instance",
+		StrategyBurnCPU:                "Run CPU intensive processes",
+		StrategyBurnIO:                 "Run disk intensive processes",
+		StrategyKillProcesses:          "Kill Java and Python programs every second",
+		StrategyNullRoute:              "Null-route the 10.0.0.0/8 network",
+		StrategyFailEC2:                "Block the EC2 API via /etc/hosts",
+		StrategyFailDNS:                "Block DNS traffic via iptables",
+		StrategyFailDynamoDB:           "Block DynamoDB via /etc/hosts",
+		StrategyFailS3:                 "Block S3 via /etc/hosts",
+		StrategyFillDisk:               "Fill up the root disk",
+		StrategyNetworkCorruption:      "Corrupt a large fraction of network packets",
+		StrategyNetworkLatency:         "Add latency of 1 second +- 50% to network packets",
+		StrategyNetworkLoss:            "Drop a fraction of network packets",
+	}
+	for _, s := range Strategies {
+		strategies = append(strategies, StrategyInfo{
+			Strategy:    s,
+			Description: descriptions[s],
+			RequiresSSH: s != StrategyShutdownInstance && s != StrategyBlockAllNetworkTraffic && s != StrategyDetachVolumes,
+		})
+	}
+}
+
+// RegisterStrategy registers a custom chaos strategy, e.g. one backed by a
+// custom chaos script of Simian Army, so that it is accepted by
+// ParseStrategy and Validate. It returns an error if a strategy with the same
+// name, ignoring case, is already registered.
+func RegisterStrategy(info StrategyInfo) error {
+	if info.Strategy == "" {
+		return errors.New("strategy name must not be empty")
+	}
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	for _, s := range strategies {
+		if strings.EqualFold(string(s.Strategy), string(info.Strategy)) {
+			return fmt.Errorf("strategy %s is already registered", s.Strategy)
+		}
+	}
+	info.Custom = true
+	strategies = append(strategies, info)
+	return nil
+}
+
+// RegisteredStrategies returns all registered chaos strategies, starting with
+// the default ones.
+func RegisteredStrategies() []StrategyInfo {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	return append([]StrategyInfo(nil), strategies...)
+}
+
+// LookupStrategy returns information about the registered strategy with the
+// given name, ignoring case.
+func LookupStrategy(s Strategy) (StrategyInfo, bool) {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	for _, info := range strategies {
+		if strings.EqualFold(string(info.Strategy), string(s)) {
+			return info, true
+		}
+	}
+	return StrategyInfo{}, false
+}
+
+// ParseStrategy returns the registered strategy matching the given name,
+// ignoring case. For unknown strategies, the returned error wraps
+// ErrUnknownStrategy and suggests similar ones.
+func ParseStrategy(name string) (Strategy, error) {
+	if info, ok := LookupStrategy(Strategy(name)); ok {
+		return info.Strategy, nil
+	}
+	return "", unknownStrategy(name)
+}
+
+// Validate returns an error if the strategy is not registered under exactly
+// this name. The empty strategy is valid and lets Chaos Monkey choose its
+// default one.
+func (s Strategy) Validate() error {
+	if s == "" {
+		return nil
+	}
+	info, ok := LookupStrategy(s)
+	if !ok {
+		return unknownStrategy(string(s))
+	}
+	if info.Strategy != s {
+		return fmt.Errorf("%w %q (did you mean %s?)", ErrUnknownStrategy, s, info.Strategy)
+	}
+	return nil
+}
+
+func unknownStrategy(name string) error {
+	suggestions := suggestStrategies(name)
+	if len(suggestions) == 0 {
+		return fmt.Errorf("%w %q", ErrUnknownStrategy, name)
+	}
+	var names []string
+	for _, s := range suggestions {
+		names = append(names, string(s))
+	}
+	return fmt.Errorf("%w %q (did you mean %s?)", ErrUnknownStrategy, name, strings.Join(names, " or "))
+}
+
+// suggestStrategies returns up to three registered strategies most similar to
+// the given name.
+func suggestStrategies(name string) []Strategy {
+	name = strings.ToLower(name)
+	if name == "" {
+		return nil
+	}
+	var (
+		suggestions []Strategy
+		best        = -1
+	)
+	for _, info := range RegisteredStrategies() {
+		s := strings.ToLower(string(info.Strategy))
+		d := levenshtein(name, s) + 1
+		switch {
+		case strings.HasPrefix(s, name):
+			d = 0
+		case len(name) >= 3 && strings.Contains(s, name):
+			d = 1
+		case d-1 > len(s)/3:
+			continue
+		}
+		if best == -1 || d < best {
+			suggestions, best = nil, d
+		}
+		if d == best && len(suggestions) < 3 {
+			suggestions = append(suggestions, info.Strategy)
+		}
+	}
+	return suggestions
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,96 @@
+package chaosmonkey_test
+
+import (
+	"errors"
+	"testing"
+
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+)
+
+func TestParseStrategy(t *testing.T) {
+	var tests = []struct {
+		name     string
+		strategy chaosmonkey.Strategy
+		err      string
+	}{
+		{"ShutdownInstance", chaosmonkey.StrategyShutdownInstance, ""},
+		{"shutdowninstance", chaosmonkey.StrategyShutdownInstance, ""},
+		{"BURNCPU", chaosmonkey.StrategyBurnCPU, ""},
+		{"Shutdown", "", `unknown chaos strategy "Shutdown" (did you mean ShutdownInstance?)`},
+		{"BurnCpo", "", `unknown chaos strategy "BurnCpo" (did you mean BurnCpu?)`},
+		{"Network", "", `unknown chaos strategy "Network" (did you mean NetworkCorruption or NetworkLatency or NetworkLoss?)`},
+		{"Foo", "", `unknown chaos strategy "Foo"`},
+	}
+	for _, tt := range tests {
+		s, err := chaosmonkey.ParseStrategy(tt.name)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: want error %q, got %v", tt.name, tt.err, err)
+			}
+			if !errors.Is(err, chaosmonkey.ErrUnknownStrategy) {
+				t.Errorf("%s: want ErrUnknownStrategy, got %v", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+		}
+		if s != tt.strategy {
+			t.Errorf("%s: want %s, got %s", tt.name, tt.strategy, s)
+		}
+	}
+}
+
+func TestStrategyValidate(t *testing.T) {
+	for _, s := range chaosmonkey.Strategies {
+		if err := s.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := chaosmonkey.Strategy("").Validate(); err != nil {
+		t.Error(err)
+	}
+	err := chaosmonkey.Strategy("burncpu").Validate()
+	if want := `unknown chaos strategy "burncpu" (did you mean BurnCpu?)`; err == nil || err.Error() != want {
+		t.Errorf("want error %q, got %v", want, err)
+	}
+}
+
+func TestRegisterStrategy(t *testing.T) {
+	custom := chaosmonkey.StrategyInfo{
+		Strategy:    "KillNginxForTest",
+		Description: "Kill nginx processes",
+		RequiresSSH: true,
+		Metadata:    map[string]string{"script": "kill-nginx.sh"},
+	}
+	if err := chaosmonkey.RegisterStrategy(custom); err != nil {
+		t.Fatal(err)
+	}
+	defer chaosmonkey.UnregisterStrategy(custom.Strategy)
+	if err := chaosmonkey.RegisterStrategy(chaosmonkey.StrategyInfo{Strategy: "killnginxfortest"}); err == nil {
+		t.Error("expected error for duplicate strategy")
+	}
+	if err := chaosmonkey.RegisterStrategy(chaosmonkey.StrategyInfo{}); err == nil {
+		t.Error("expected error for empty strategy")
+	}
+
+	s, err := chaosmonkey.ParseStrategy("killnginxfortest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Validate(); err != nil {
+		t.Error(err)
+	}
+	info, ok := chaosmonkey.LookupStrategy(s)
+	if !ok || !info.Custom || !info.RequiresSSH || info.Metadata["script"] != "kill-nginx.sh" {
+		t.Errorf("unexpected strategy info %+v", info)
+	}
+
+	all := chaosmonkey.RegisteredStrategies()
+	if all[0].Strategy != chaosmonkey.StrategyShutdownInstance || all[0].RequiresSSH {
+		t.Errorf("unexpected first strategy %+v", all[0])
+	}
+	if all[len(all)-1].Strategy != custom.Strategy {
+		t.Errorf("custom strategy not registered last")
+	}
+}
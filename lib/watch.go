@@ -0,0 +1,77 @@
+package chaosmonkey
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// DefaultWatchInterval is the interval used by WatchEvents if the given one is
+// not positive.
+const DefaultWatchInterval = 5 * time.Second
+
+// WatchEvents polls the API at the given interval and sends all chaos events
+// triggered after the given time to the returned channel, oldest first. Each
+// event is only sent once. Failed polls are retried at the next interval. The
+// channel is closed after the context is done.
+func (c *Client) WatchEvents(ctx context.Context, since time.Time, interval time.Duration) <-chan Event {
+	ch := make(chan Event)
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	go func() {
+		defer close(ch)
+
+		// Event times are truncated to seconds
+		cursor := since.UTC().Truncate(time.Second)
+		seen := make(map[eventKey]bool)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			// Overlap with the previous poll as the API only accepts
+			// whole seconds; duplicates are filtered below
+			events, err := c.EventsSinceContext(ctx, cursor.Add(-time.Second))
+			if err == nil {
+				sort.SliceStable(events, func(i, j int) bool {
+					return events[i].TriggeredAt.Before(events[j].TriggeredAt)
+				})
+				for _, e := range events {
+					k := eventKey{e.InstanceID, e.TriggeredAt}
+					if e.TriggeredAt.Before(cursor) || seen[k] {
+						continue
+					}
+					seen[k] = true
+					select {
+					case ch <- e:
+					case <-ctx.Done():
+						return
+					}
+				}
+				if n := len(events); n > 0 && events[n-1].TriggeredAt.After(cursor) {
+					cursor = events[n-1].TriggeredAt
+					for k := range seen {
+						if k.triggeredAt.Before(cursor) {
+							delete(seen, k)
+						}
+					}
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// eventKey identifies an event for de-duplication.
+type eventKey struct {
+	instanceID  string
+	triggeredAt time.Time
+}
@@ -0,0 +1,68 @@
+package chaosmonkey_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+	"github.com/mlafeldt/chaosmonkey/lib/chaosmonkeytest"
+)
+
+func TestWatchEvents(t *testing.T) {
+	s := chaosmonkeytest.NewServer(nil)
+	defer s.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	s.AddEvent(chaosmonkey.Event{InstanceID: "i-old", TriggeredAt: now.Add(-time.Hour)})
+	s.AddEvent(chaosmonkey.Event{InstanceID: "i-1", TriggeredAt: now})
+
+	c, err := chaosmonkey.NewClient(&chaosmonkey.Config{Endpoint: s.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := c.WatchEvents(ctx, now, 10*time.Millisecond)
+
+	next := func() string {
+		select {
+		case e := <-ch:
+			return e.InstanceID
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for event")
+		}
+		return ""
+	}
+
+	if id := next(); id != "i-1" {
+		t.Fatalf("expected i-1, got %s", id)
+	}
+	s.AddEvent(chaosmonkey.Event{InstanceID: "i-2", TriggeredAt: now})
+	s.AddEvent(chaosmonkey.Event{InstanceID: "i-3", TriggeredAt: now.Add(time.Second)})
+	if id := next(); id != "i-2" {
+		t.Fatalf("expected i-2, got %s", id)
+	}
+	if id := next(); id != "i-3" {
+		t.Fatalf("expected i-3, got %s", id)
+	}
+
+	select {
+	case e := <-ch:
+		t.Fatalf("unexpected event %+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	for range ch {
+	}
+
+	// A non-positive interval falls back to the default
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	ch = c.WatchEvents(ctx, now, 0)
+	if id := next(); id == "" || id == "i-old" {
+		t.Fatalf("unexpected event %q", id)
+	}
+}
@@ -1,142 +1,392 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"math/rand"
-	"net/http"
 	"os"
-	"runtime"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
-	"github.com/ryanuber/columnize"
-
+	"github.com/mlafeldt/chaosmonkey/audit"
 	"github.com/mlafeldt/chaosmonkey/aws"
+	"github.com/mlafeldt/chaosmonkey/experiment"
+	"github.com/mlafeldt/chaosmonkey/journal"
 	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
 )
 
 func main() {
+	flag.Usage = usage
+	args := os.Args[1:]
+
+	if len(args) > 0 && (args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+		if cmd, _ := findCommand(args[1:]); cmd != nil {
+			fs := cmd.newFlagSet()
+			cmd.setup(fs)
+			fs.Usage()
+			return
+		}
+		usage()
+		return
+	}
+
+	registerStrategies()
+
+	if cmd, rest := findCommand(args); cmd != nil {
+		cmd.execute(rest)
+		flushOutput()
+		return
+	}
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		fmt.Fprintf(os.Stderr, "error: unknown command %q\n\n", args[0])
+		usage()
+		os.Exit(2)
+	}
+	runLegacy(args)
+}
+
+// registerStrategies registers the custom strategies defined in the
+// configuration file, if any.
+func registerStrategies() {
+	f, err := chaosmonkey.ReadConfigFile(chaosmonkey.DefaultConfigFile())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil {
+		err = f.RegisterStrategies()
+	}
+	if err != nil {
+		abort("failed to read config file: %s", err)
+	}
+}
+
+// runLegacy supports the deprecated form of the CLI, where flags select what
+// to do, by translating it into the corresponding command.
+func runLegacy(args []string) {
+	cmd, cmdArgs, err := translateLegacy(flag.CommandLine, args)
+	if err != nil {
+		abort("%s", err)
+	}
+	fmt.Fprintf(os.Stderr, "warning: invoking chaosmonkey without a command is deprecated, use 'chaosmonkey %s' instead\n", cmd.name)
+	cmd.execute(cmdArgs)
+	flushOutput()
+}
+
+// translateLegacy parses the arguments of the legacy form of the CLI with the
+// given flag set, and returns the command to execute instead along with its
+// arguments.
+func translateLegacy(fs *flag.FlagSet, args []string) (*command, []string, error) {
 	var (
-		endpoint = flag.String("endpoint", "", "Address and port of Chaos Monkey API server")
-		region   = flag.String("region", "", "Name of AWS region (ignored by vanilla Chaos Monkey)")
-		username = flag.String("username", "", "Username for HTTP basic authentication")
-		password = flag.String("password", "", "Password for HTTP basic authentication")
-
-		group    = flag.String("group", "", "Name of auto scaling group, see -list-groups")
-		strategy = flag.String("strategy", "", "Chaos strategy to use, see -list-strategies")
-
-		count       = flag.Int("count", 1, "Number of times to trigger chaos event")
-		interval    = flag.Duration("interval", 5*time.Second, "Time to wait between chaos events")
-		probability = flag.Float64("probability", 1.0, "Probability of chaos events")
-
-		listStrategies = flag.Bool("list-strategies", false, "List chaos strategies")
-		listGroups     = flag.Bool("list-groups", false, "List auto scaling groups")
-		wipeState      = flag.String("wipe-state", "", "Wipe state of Chaos Monkey by deleting given SimpleDB domain")
-		showVersion    = flag.Bool("version", false, "Show program version")
+		listStrategies = fs.Bool("list-strategies", false, "List chaos strategies")
+		listGroups     = fs.Bool("list-groups", false, "List auto scaling groups")
+		wipeState      = fs.String("wipe-state", "", "Wipe state of Chaos Monkey by deleting given SimpleDB domain")
+		showVersion    = fs.Bool("version", false, "Show program version")
 	)
-	flag.Parse()
 
-	if flag.NArg() > 0 {
-		abort("program expects no arguments, but %d given", flag.NArg())
+	// Accept the flags of all commands and record them to pass them on
+	var recorded []legacyArg
+	for _, cmd := range commands {
+		cfs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+		cmd.setup(cfs)
+		cfs.VisitAll(func(f *flag.Flag) {
+			if fs.Lookup(f.Name) != nil {
+				return
+			}
+			b, ok := f.Value.(interface{ IsBoolFlag() bool })
+			fs.Var(&legacyFlag{name: f.Name, bool: ok && b.IsBoolFlag(), args: &recorded}, f.Name, f.Usage)
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
 	}
 
+	name, positional := "events", fs.Args()
 	switch {
 	case *listStrategies:
-		for _, s := range chaosmonkey.Strategies {
-			fmt.Println(s)
-		}
-		return
+		name = "strategies"
 	case *listGroups:
-		groups, err := aws.NewClient(*region).AutoScalingGroups()
-		if err != nil {
-			abort("failed to get auto scaling groups: %s", err)
-		}
-		listAutoScalingGroups(groups)
-		return
+		name = "groups"
 	case *wipeState != "":
-		if err := aws.NewClient(*region).DeleteSimpleDBDomain(*wipeState); err != nil {
-			abort("failed to wipe state: %s", err)
-		}
-		return
+		name, positional = "state wipe", []string{*wipeState}
 	case *showVersion:
-		fmt.Printf("chaosmonkey %s %s/%s %s\n", Version,
-			runtime.GOOS, runtime.GOARCH, runtime.Version())
-		return
+		name = "version"
+	case fs.Arg(0) == "run" || fs.Arg(0) == "audit":
+		name, positional = fs.Arg(0), fs.Args()[1:]
+	default:
+		for _, a := range recorded {
+			if a.name == "group" && a.value != "" {
+				name = "trigger"
+			}
+		}
+	}
+	if name == "events" && len(positional) > 0 {
+		return nil, nil, fmt.Errorf("program expects no arguments, but %d given", len(positional))
 	}
 
-	client, err := chaosmonkey.NewClient(&chaosmonkey.Config{
-		Endpoint:   *endpoint,
-		Region:     *region,
-		Username:   *username,
-		Password:   *password,
-		UserAgent:  fmt.Sprintf("chaosmonkey Go client %s", Version),
-		HTTPClient: &http.Client{Timeout: 10 * time.Second},
-	})
+	// Only pass on the flags known to the command
+	cmd, _ := findCommand(strings.Fields(name))
+	cfs := cmd.newFlagSet()
+	cmd.setup(cfs)
+	var cmdArgs []string
+	for _, a := range recorded {
+		if cfs.Lookup(a.name) != nil {
+			cmdArgs = append(cmdArgs, "-"+a.name+"="+a.value)
+		}
+	}
+	return cmd, append(cmdArgs, positional...), nil
+}
+
+// legacyArg is a flag given in the legacy form of the CLI.
+type legacyArg struct {
+	name, value string
+}
+
+// legacyFlag is a flag.Value recording all values of a flag.
+type legacyFlag struct {
+	name string
+	bool bool
+	args *[]legacyArg
+}
+
+func (f *legacyFlag) String() string { return "" }
+
+func (f *legacyFlag) Set(s string) error {
+	*f.args = append(*f.args, legacyArg{f.name, s})
+	return nil
+}
+
+func (f *legacyFlag) IsBoolFlag() bool { return f.bool }
+
+// runExperiment executes the experiment, printing triggered events as they
+// occur, until it is finished or the program is interrupted. In dry run mode,
+// the requests that would be sent are logged instead. If reportDir is set, a
+// report of the run is written to it, even if the run failed. Faults recorded
+// in the journal are reverted once their duration has passed, or rolled back
+// if the run is interrupted or the steady-state hypothesis fails. The program
+// exits with an error if the chaos events could not be written to the audit
+// log.
+func runExperiment(runner *experiment.Runner, e *experiment.Experiment, reportDir string, faults *faultJournal, auditor *auditRecorder) *experiment.Summary {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	rand.Seed(time.Now().UTC().UnixNano())
+	runner.OnEvent = func(e chaosmonkey.Event) { printEvents(e) }
+	runner.AutoScaling = func(region string) experiment.AutoScaling {
+		return aws.NewClient(region)
+	}
+	faults.start(ctx)
+	summary, err := runner.Run(ctx, e)
+	if err := faults.settle(ctx, err); err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed to roll back: %s\n", err)
+	}
+	if reportDir != "" {
+		paths, err := runner.NewReport(e, summary, err).Write(reportDir)
+		if err != nil {
+			abort("failed to write report: %s", err)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote report to %s\n", strings.Join(paths, ", "))
+	}
+	auditErr := auditor.failure()
+	if auditErr != nil {
+		fmt.Fprintf(os.Stderr, "error: %s, chaos events of this run may be missing from it\n", auditErr)
+	}
 	if err != nil {
+		flushOutput()
 		abort("%s", err)
 	}
+	if auditErr != nil {
+		flushOutput()
+		os.Exit(1)
+	}
+	return summary
+}
 
-	if *group != "" {
-		rand.Seed(time.Now().UTC().UnixNano())
-		skipped := 0
-		for i := 1; i <= *count; i++ {
-			if rand.Float64() > *probability {
-				skipped++
-			} else {
-				event, err := client.TriggerEvent(*group, chaosmonkey.Strategy(*strategy))
-				if err != nil {
-					abort("%s", err)
-				}
-				printEvents(*event)
-			}
-			if i < *count {
-				time.Sleep(*interval)
-			}
-		}
-		if skipped > 0 {
-			fmt.Fprintf(os.Stderr, "Skipped %d chaos event(s) with probability of %f\n", skipped, *probability)
+// watchEvents prints new chaos events matching the query as they appear, until
+// the program is interrupted.
+func watchEvents(client *chaosmonkey.Client, query chaosmonkey.EventQuery, interval time.Duration) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	since := query.Since
+	if since.IsZero() {
+		since = time.Now()
+	}
+	for e := range client.WatchEvents(ctx, since, interval) {
+		if query.Match(&e) {
+			printEvents(e)
 		}
-	} else {
-		events, err := client.Events()
-		if err != nil {
-			abort("%s", err)
+	}
+}
+
+// probeList is a flag.Value collecting probes.
+type probeList []experiment.Probe
+
+func (l *probeList) String() string {
+	var s []string
+	for i := range *l {
+		s = append(s, (*l)[i].String())
+	}
+	return strings.Join(s, ", ")
+}
+
+func (l *probeList) Set(s string) error {
+	p, err := experiment.ParseProbe(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, *p)
+	return nil
+}
+
+// stringList is a flag.Value collecting strings.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// parseHeaders parses HTTP headers in the form "Name: value".
+func parseHeaders(headers []string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, h := range headers {
+		i := strings.Index(h, ":")
+		if i < 1 {
+			return nil, fmt.Errorf("header %q must be in the form 'Name: value'", h)
 		}
-		printEvents(events...)
+		m[strings.TrimSpace(h[:i])] = strings.TrimSpace(h[i+1:])
+	}
+	return m, nil
+}
+
+// parseTime parses either an RFC 3339 time or a duration, which is relative to
+// the current time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
 	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// output is the printer used for all lists printed to stdout.
+var output *printer
+
+func flushOutput() {
+	if output == nil {
+		return
+	}
+	if err := output.flush(); err != nil {
+		abort("failed to print output: %s", err)
+	}
+}
+
+func printTable(t table) {
+	if err := output.print(t); err != nil {
+		abort("failed to print output: %s", err)
+	}
+}
+
+func printStrategyDetails(strategies []chaosmonkey.StrategyInfo) {
+	t := table{columns: []string{"Strategy", "RequiresSSH", "Custom", "Description"}}
+	for _, s := range strategies {
+		t.rows = append(t.rows, []interface{}{s.Strategy, s.RequiresSSH, s.Custom, s.Description})
+		t.items = append(t.items, s)
+	}
+	printTable(t)
+}
+
+func printStrategies(strategies []chaosmonkey.Strategy) {
+	t := table{columns: []string{"Strategy"}, noHeader: true}
+	for _, s := range strategies {
+		t.rows = append(t.rows, []interface{}{s})
+		t.items = append(t.items, s)
+	}
+	printTable(t)
 }
 
 func listAutoScalingGroups(groups []aws.AutoScalingGroup) {
-	lines := []string{"AutoScalingGroupName|Instances|Desired|Min|Max"}
+	t := table{columns: []string{"AutoScalingGroupName", "Instances", "Desired", "Min", "Max"}}
 	for _, g := range groups {
-		lines = append(lines, fmt.Sprintf("%s|%d|%d|%d|%d",
+		t.rows = append(t.rows, []interface{}{
 			g.Name,
 			g.InstancesInService,
 			g.DesiredCapacity,
 			g.MinSize,
 			g.MaxSize,
-		))
+		})
+		t.items = append(t.items, g)
 	}
-	fmt.Println(columnize.SimpleFormat(lines))
+	printTable(t)
 }
 
-var addHeader = true
-
 func printEvents(event ...chaosmonkey.Event) {
-	var lines []string
-	if addHeader {
-		lines = append(lines, "InstanceID|AutoScalingGroupName|Region|Strategy|TriggeredAt")
-		addHeader = false
-	}
+	t := table{columns: []string{"InstanceID", "AutoScalingGroupName", "Region", "Strategy", "TriggeredAt"}}
 	for _, e := range event {
-		lines = append(lines, fmt.Sprintf("%s|%s|%s|%s|%s",
+		t.rows = append(t.rows, []interface{}{
 			e.InstanceID,
 			e.AutoScalingGroupName,
 			e.Region,
 			e.Strategy,
 			e.TriggeredAt.Format(time.RFC3339),
-		))
+		})
+		t.items = append(t.items, e)
+	}
+	printTable(t)
+}
+
+func printAuditEntries(entries []audit.Entry) {
+	t := table{columns: []string{"Time", "Operator", "Command", "Target", "Strategy", "InstanceID", "Error"}}
+	for _, e := range entries {
+		target, instanceID := e.Group, ""
+		if e.Command == audit.CommandWipeState {
+			target = e.Domain
+		}
+		if e.Event != nil {
+			instanceID = e.Event.InstanceID
+		}
+		t.rows = append(t.rows, []interface{}{
+			e.Time.Format(time.RFC3339),
+			e.Operator,
+			e.Command,
+			target,
+			e.Strategy,
+			instanceID,
+			e.Error,
+		})
+		t.items = append(t.items, e)
+	}
+	printTable(t)
+}
+
+func printJournalEntries(entries []journal.Entry) {
+	t := table{columns: []string{"RunID", "InstanceID", "AutoScalingGroupName", "Region", "Strategy", "TriggeredAt", "Expires"}}
+	for _, e := range entries {
+		expires := ""
+		if t := e.Fault.Expires(); !t.IsZero() {
+			expires = t.Format(time.RFC3339)
+		}
+		t.rows = append(t.rows, []interface{}{
+			e.RunID,
+			e.Fault.InstanceID,
+			e.Fault.AutoScalingGroupName,
+			e.Fault.Region,
+			e.Fault.Strategy,
+			e.Fault.TriggeredAt.Format(time.RFC3339),
+			expires,
+		})
+		t.items = append(t.items, e)
 	}
-	fmt.Println(columnize.SimpleFormat(lines))
+	printTable(t)
 }
 
 func abort(format string, a ...interface{}) {
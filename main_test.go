@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTranslateLegacy(t *testing.T) {
+	var tests = []struct {
+		args    []string
+		command string
+		cmdArgs []string
+	}{
+		{nil, "events", nil},
+		{[]string{"-list-groups", "-region", "x"}, "groups", []string{"-region=x"}},
+		{[]string{"-list-strategies"}, "strategies", nil},
+		{[]string{"-wipe-state", "D"}, "state wipe", []string{"D"}},
+		{[]string{"-group", "g", "-count", "2", "-interval", "1s"}, "trigger", []string{"-group=g", "-count=2", "-interval=1s"}},
+		{[]string{"--version"}, "version", nil},
+		{[]string{"-endpoint", "http://x", "-dry-run", "run", "exp.yaml"}, "run", []string{"-endpoint=http://x", "-dry-run=true", "exp.yaml"}},
+		{[]string{"-filter-group", "g", "audit"}, "audit", []string{"-filter-group=g"}},
+		{[]string{"-since", "1h"}, "events", []string{"-since=1h"}},
+	}
+
+	for i, tt := range tests {
+		fs := flag.NewFlagSet("chaosmonkey", flag.ContinueOnError)
+		cmd, args, err := translateLegacy(fs, tt.args)
+		if err != nil {
+			t.Errorf("%d. %s", i, err)
+			continue
+		}
+		if cmd.name != tt.command {
+			t.Errorf("%d. want command %q, got %q", i, tt.command, cmd.name)
+		}
+		if diff := cmp.Diff(tt.cmdArgs, args); diff != "" {
+			t.Errorf("%d. %s", i, diff)
+		}
+	}
+
+	for _, args := range [][]string{{"foo"}, {"-unknown"}} {
+		fs := flag.NewFlagSet("chaosmonkey", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		if _, _, err := translateLegacy(fs, args); err == nil {
+			t.Errorf("%q: expected error", args)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/ryanuber/columnize"
+	"gopkg.in/yaml.v2"
+)
+
+// Supported output formats
+var outputFormats = []string{"table", "json", "jsonl", "csv", "yaml", "template"}
+
+// table describes a list of items to print, with one row per item.
+type table struct {
+	columns  []string
+	rows     [][]interface{}
+	items    []interface{}
+	noHeader bool // omit header in table format
+}
+
+// printer prints tables in one of the supported output formats. Formats that
+// cannot be streamed, like JSON, are buffered until flush is called.
+type printer struct {
+	w          io.Writer
+	format     string
+	template   *template.Template
+	wroteTable bool
+	wroteCSV   bool
+	used       bool
+	pending    []table
+}
+
+func newPrinter(w io.Writer, format, tmpl string) (*printer, error) {
+	p := &printer{w: w, format: format}
+	switch format {
+	case "table", "json", "jsonl", "csv", "yaml":
+	case "template":
+		t, err := template.New("output").Parse(tmpl)
+		if err != nil {
+			return nil, err
+		}
+		p.template = t
+	default:
+		return nil, fmt.Errorf("unknown output format %q, must be one of %v", format, outputFormats)
+	}
+	return p, nil
+}
+
+func (p *printer) print(t table) error {
+	p.used = true
+	switch p.format {
+	case "table":
+		var lines []string
+		if !t.noHeader && !p.wroteTable {
+			lines = append(lines, strings.Join(t.columns, "|"))
+			p.wroteTable = true
+		}
+		for _, row := range t.rows {
+			lines = append(lines, joinColumns(row))
+		}
+		if len(lines) == 0 {
+			return nil
+		}
+		_, err := fmt.Fprintln(p.w, columnize.SimpleFormat(lines))
+		return err
+	case "csv":
+		w := csv.NewWriter(p.w)
+		if !p.wroteCSV {
+			w.Write(t.columns)
+			p.wroteCSV = true
+		}
+		for _, row := range t.rows {
+			var record []string
+			for _, v := range row {
+				record = append(record, fmt.Sprint(v))
+			}
+			w.Write(record)
+		}
+		w.Flush()
+		return w.Error()
+	case "jsonl":
+		for _, row := range t.rows {
+			b, err := objectJSON(t.columns, row)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(p.w, "%s\n", b); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "template":
+		for _, item := range t.items {
+			if err := p.template.Execute(p.w, item); err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintln(p.w); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		p.pending = append(p.pending, t)
+		return nil
+	}
+}
+
+// flush prints all buffered tables as a single list.
+func (p *printer) flush() error {
+	if !p.used {
+		return nil
+	}
+	switch p.format {
+	case "json":
+		objects := []json.RawMessage{}
+		for _, t := range p.pending {
+			for _, row := range t.rows {
+				b, err := objectJSON(t.columns, row)
+				if err != nil {
+					return err
+				}
+				objects = append(objects, b)
+			}
+		}
+		b, err := json.MarshalIndent(objects, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(p.w, "%s\n", b)
+		return err
+	case "yaml":
+		objects := []yaml.MapSlice{}
+		for _, t := range p.pending {
+			for _, row := range t.rows {
+				var m yaml.MapSlice
+				for i, c := range t.columns {
+					m = append(m, yaml.MapItem{Key: c, Value: row[i]})
+				}
+				objects = append(objects, m)
+			}
+		}
+		b, err := yaml.Marshal(objects)
+		if err != nil {
+			return err
+		}
+		_, err = p.w.Write(b)
+		return err
+	}
+	return nil
+}
+
+// objectJSON encodes a row as JSON object, preserving the order of columns.
+func objectJSON(columns []string, row []interface{}) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, c := range columns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(row[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func joinColumns(row []interface{}) string {
+	var buf bytes.Buffer
+	for i, v := range row {
+		if i > 0 {
+			buf.WriteByte('|')
+		}
+		fmt.Fprint(&buf, v)
+	}
+	return buf.String()
+}
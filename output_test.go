@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+type testItem struct {
+	Name  string
+	Count int
+}
+
+func testTable(items ...testItem) table {
+	t := table{columns: []string{"Name", "Count"}}
+	for _, it := range items {
+		t.rows = append(t.rows, []interface{}{it.Name, it.Count})
+		t.items = append(t.items, it)
+	}
+	return t
+}
+
+func TestPrinter(t *testing.T) {
+	var (
+		twoCalls = []table{testTable(testItem{"a", 1}), testTable(testItem{"bb", 22})}
+		empty    = []table{testTable()}
+	)
+
+	var tests = []struct {
+		format string
+		tmpl   string
+		tables []table
+		want   string
+	}{
+		{"table", "", twoCalls, "Name  Count\na     1\n" + "bb  22\n"},
+		{"table", "", empty, "Name  Count\n"},
+		{"table", "", []table{{columns: []string{"Name"}, rows: [][]interface{}{{"a"}}, noHeader: true}}, "a\n"},
+		{"csv", "", twoCalls, "Name,Count\na,1\nbb,22\n"},
+		{"csv", "", empty, "Name,Count\n"},
+		{"json", "", twoCalls, "[\n  {\n    \"Name\": \"a\",\n    \"Count\": 1\n  },\n  {\n    \"Name\": \"bb\",\n    \"Count\": 22\n  }\n]\n"},
+		{"json", "", empty, "[]\n"},
+		{"jsonl", "", twoCalls, "{\"Name\":\"a\",\"Count\":1}\n{\"Name\":\"bb\",\"Count\":22}\n"},
+		{"jsonl", "", empty, ""},
+		{"yaml", "", twoCalls, "- Name: a\n  Count: 1\n- Name: bb\n  Count: 22\n"},
+		{"yaml", "", empty, "[]\n"},
+		{"template", "{{.Name}}={{.Count}}", twoCalls, "a=1\nbb=22\n"},
+		{"template", "{{.Name}}={{.Count}}", empty, ""},
+	}
+
+	for i, tt := range tests {
+		var buf bytes.Buffer
+		p, err := newPrinter(&buf, tt.format, tt.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tab := range tt.tables {
+			if err := p.print(tab); err != nil {
+				t.Fatalf("%d. %s", i, err)
+			}
+		}
+		if err := p.flush(); err != nil {
+			t.Fatalf("%d. %s", i, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%d. %s: want %q, got %q", i, tt.format, tt.want, got)
+		}
+	}
+}
+
+func TestPrinterUnused(t *testing.T) {
+	for _, format := range []string{"json", "yaml"} {
+		var buf bytes.Buffer
+		p, err := newPrinter(&buf, format, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.flush(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("%s: want no output, got %q", format, buf.String())
+		}
+	}
+}
+
+func TestNewPrinterErrors(t *testing.T) {
+	if _, err := newPrinter(nil, "xml", ""); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	if _, err := newPrinter(nil, "template", "{{.Name"); err == nil {
+		t.Error("expected error for invalid template")
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mlafeldt/chaosmonkey/audit"
+	"github.com/mlafeldt/chaosmonkey/aws"
+	"github.com/mlafeldt/chaosmonkey/experiment"
+	"github.com/mlafeldt/chaosmonkey/journal"
+)
+
+// faultJournal records the faults injected by a run of the native backend in
+// the journal, so that they can be rolled back, and reverts faults with a
+// duration once it has passed.
+type faultJournal struct {
+	journal *journal.Journal
+	path    string
+	runID   string
+
+	// Whether to roll back the faults if the run is interrupted or the
+	// steady-state hypothesis fails
+	auto bool
+
+	// Audit log recording rollbacks, if any
+	auditor *auditRecorder
+
+	// Context of reversals in progress, canceled if the run is interrupted
+	// or the timers are stopped, see start
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Guards the fields below, which are also used by the timers reverting
+	// faults
+	mu     sync.Mutex
+	seq    int
+	timers map[int]*time.Timer
+	until  time.Time
+	wg     sync.WaitGroup
+}
+
+// Maximum time to revert a fault whose duration has passed, e.g. to wait for
+// SSM to run the revert script
+var expireTimeout = 10 * time.Minute
+
+// revertFault reverts the fault, which is replaced in tests.
+var revertFault = func(ctx context.Context, f *aws.Fault) error {
+	return aws.NewClient(f.Region).RevertFault(ctx, f)
+}
+
+// openJournal opens the journal at the given path for a new run, after
+// reverting expired faults left in it. It returns nil if path is empty, i.e.
+// journaling is disabled.
+func openJournal(path string, auto bool, auditor *auditRecorder) *faultJournal {
+	if path == "" {
+		return nil
+	}
+	revertExpiredFaults(path, auditor)
+	j, err := journal.Open(path)
+	if err != nil {
+		abort("failed to open journal: %s", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &faultJournal{
+		journal: j,
+		path:    path,
+		runID:   journal.NewRunID(),
+		auto:    auto,
+		auditor: auditor,
+		ctx:     ctx,
+		cancel:  cancel,
+		timers:  make(map[int]*time.Timer),
+	}
+}
+
+// start binds the reversal of faults whose duration has passed to the context
+// of the run. It must be called before the run starts.
+func (j *faultJournal) start(ctx context.Context) {
+	if j != nil {
+		j.cancel()
+		j.ctx, j.cancel = context.WithCancel(ctx)
+	}
+}
+
+// record adds the fault to the journal if it can be reverted, and schedules its
+// reversal if it has a duration. As the fault has already been applied, a
+// failure to record it is only reported.
+func (j *faultJournal) record(f *aws.Fault) {
+	if j == nil || !f.Reversible() {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.seq++
+	e := journal.Entry{
+		RunID:  j.runID,
+		Seq:    j.seq,
+		Action: journal.ActionApply,
+		Fault:  f,
+	}
+	if err := j.journal.Record(e); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to record %s on instance %s in journal: %s\n",
+			f.Strategy, f.InstanceID, err)
+	} else if j.seq == 1 {
+		fmt.Fprintf(os.Stderr, "Recording faults in journal, roll back with 'chaosmonkey rollback -run-id %s'\n", j.runID)
+	}
+
+	if expires := f.Expires(); !expires.IsZero() {
+		if expires.After(j.until) {
+			j.until = expires
+		}
+		j.wg.Add(1)
+		j.timers[e.Seq] = time.AfterFunc(time.Until(expires), func() {
+			defer j.wg.Done()
+			j.expire(e)
+		})
+	}
+}
+
+// expire reverts the fault of the entry whose duration has passed, unless its
+// timer was stopped meanwhile. The fault remains pending if it cannot be
+// reverted in time or the run is interrupted.
+func (j *faultJournal) expire(e journal.Entry) {
+	j.mu.Lock()
+	_, ok := j.timers[e.Seq]
+	delete(j.timers, e.Seq)
+	j.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	// Don't hold the lock, as reverting can take long
+	ctx, cancel := context.WithTimeout(j.ctx, expireTimeout)
+	defer cancel()
+	if err := revertFaults(ctx, j.journal, []journal.Entry{e}, j.auditor); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+	}
+}
+
+// stopTimers stops the reversal of faults whose duration has not passed yet,
+// and cancels reversals in progress, whose faults remain pending.
+func (j *faultJournal) stopTimers() {
+	j.mu.Lock()
+	for seq, t := range j.timers {
+		if t.Stop() {
+			j.wg.Done()
+		}
+		delete(j.timers, seq)
+	}
+	j.mu.Unlock()
+	j.cancel()
+	j.wg.Wait()
+}
+
+// wait waits until the faults with a duration have been reverted, or the
+// context is done.
+func (j *faultJournal) wait(ctx context.Context) {
+	j.mu.Lock()
+	n, until := len(j.timers), j.until
+	j.mu.Unlock()
+	if n == 0 || ctx.Err() != nil {
+		return
+	}
+
+	msg := fmt.Sprintf("Waiting until %s for %d fault(s) to expire", until.Local().Format(time.Kitchen), n)
+	if j.auto {
+		msg += ", interrupt to roll back now"
+	}
+	fmt.Fprintln(os.Stderr, msg)
+
+	done := make(chan struct{})
+	go func() {
+		j.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// settle reverts the faults of the run as needed after it ended with the given
+// error. If the run was interrupted or the steady-state hypothesis failed, all
+// faults are rolled back, unless automatic rollback is disabled. Otherwise, it
+// waits for faults with a duration to be reverted, rolling back if interrupted
+// meanwhile. Faults left in place expire on the next run using the journal.
+func (j *faultJournal) settle(ctx context.Context, err error) error {
+	if j == nil {
+		return nil
+	}
+	failed := errors.Is(err, experiment.ErrHypothesisFailed)
+	if !failed || !j.auto {
+		j.wait(ctx)
+	}
+	j.stopTimers()
+	if !j.auto || (ctx.Err() == nil && !failed) {
+		return nil
+	}
+
+	j.mu.Lock()
+	seq := j.seq
+	j.mu.Unlock()
+	if seq == 0 {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "Rolling back faults of run %s\n", j.runID)
+	return rollbackFaults(j.path, j.runID, j.auditor)
+}
+
+func (j *faultJournal) close() {
+	if j != nil {
+		j.cancel()
+		j.journal.Close()
+	}
+}
+
+// rollbackFaults reverts the pending faults in the journal at the given path,
+// most recent first. If runID is not empty, only faults of that run are
+// reverted. Faults that cannot be reverted remain pending.
+func rollbackFaults(path, runID string, auditor *auditRecorder) error {
+	pending, err := journal.Pending(path, runID)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		fmt.Fprintln(os.Stderr, "No faults to roll back")
+		return nil
+	}
+	j, err := journal.Open(path)
+	if err != nil {
+		return err
+	}
+	defer j.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return revertFaults(ctx, j, pending, auditor)
+}
+
+// revertExpiredFaults reverts the pending faults in the journal at the given
+// path whose duration has passed, e.g. because the program was killed before
+// it could revert them. Failures are only reported.
+func revertExpiredFaults(path string, auditor *auditRecorder) {
+	expired, err := journal.Expired(path, time.Now())
+	if err == nil && len(expired) > 0 {
+		fmt.Fprintf(os.Stderr, "Reverting %d fault(s) in %s whose duration has passed\n", len(expired), path)
+		var j *journal.Journal
+		if j, err = journal.Open(path); err == nil {
+			err = revertFaults(context.Background(), j, expired, auditor)
+			j.Close()
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %s, see 'chaosmonkey rollback -dry-run'\n", err)
+	}
+}
+
+// revertFaults reverts the faults of the entries in the given order, recording
+// each reverted fault in the journal and every attempt in the audit log. Faults
+// of instances that have been terminated meanwhile count as reverted.
+func revertFaults(ctx context.Context, j *journal.Journal, entries []journal.Entry, auditor *auditRecorder) error {
+	failed := 0
+	for _, e := range entries {
+		f := e.Fault
+		err := revertFault(ctx, f)
+		entry := audit.Entry{
+			Command:  audit.CommandRollback,
+			Group:    f.AutoScalingGroupName,
+			Strategy: f.Strategy,
+			Region:   f.Region,
+			Event:    &f.Event,
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		if aerr := auditor.record(entry); aerr != nil {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", aerr)
+		}
+		gone := errors.Is(err, aws.ErrInstanceGone)
+		if err != nil && !gone {
+			fmt.Fprintf(os.Stderr, "Failed to revert %s on instance %s: %s\n", f.Strategy, f.InstanceID, err)
+			failed++
+			continue
+		}
+		if rerr := j.Record(journal.Entry{RunID: e.RunID, Seq: e.Seq, Action: journal.ActionRevert, Fault: f}); rerr != nil {
+			return fmt.Errorf("failed to write journal: %s", rerr)
+		}
+		if gone {
+			fmt.Fprintf(os.Stderr, "Instance %s no longer exists, nothing to revert for %s\n", f.InstanceID, f.Strategy)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Reverted %s on instance %s\n", f.Strategy, f.InstanceID)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to revert %d of %d fault(s)", failed, len(entries))
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/mlafeldt/chaosmonkey/aws"
+	"github.com/mlafeldt/chaosmonkey/journal"
+	chaosmonkey "github.com/mlafeldt/chaosmonkey/lib"
+)
+
+// fakeReverter replaces revertFault, blocking the first reversal of the given
+// instance until the context is done.
+type fakeReverter struct {
+	slow    string
+	started chan struct{}
+
+	mu       sync.Mutex
+	blocked  bool
+	reverted []string
+}
+
+func newFakeReverter(slow string) *fakeReverter {
+	r := &fakeReverter{slow: slow, started: make(chan struct{})}
+	revertFault = r.revert
+	return r
+}
+
+func (r *fakeReverter) revert(ctx context.Context, f *aws.Fault) error {
+	r.mu.Lock()
+	block := f.InstanceID == r.slow && !r.blocked
+	r.blocked = r.blocked || block
+	r.mu.Unlock()
+	if block {
+		close(r.started)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	r.mu.Lock()
+	r.reverted = append(r.reverted, f.InstanceID)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *fakeReverter) instances() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := append([]string(nil), r.reverted...)
+	sort.Strings(ids)
+	return ids
+}
+
+func newFault(id string, d time.Duration) *aws.Fault {
+	return &aws.Fault{
+		Event: chaosmonkey.Event{
+			InstanceID:  id,
+			Strategy:    chaosmonkey.StrategyBurnCPU,
+			TriggeredAt: time.Now(),
+		},
+		RevertScript: "pkill burn",
+		Duration:     d,
+	}
+}
+
+func openTestJournal(t *testing.T, auto bool) (*faultJournal, string) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "journal.log")
+	return openJournal(path, auto, nil), path
+}
+
+func checkPending(t *testing.T, path string, want int) {
+	t.Helper()
+	pending, err := journal.Pending(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != want {
+		t.Errorf("want %d pending fault(s), got %d", want, len(pending))
+	}
+}
+
+func TestFaultJournalSettle(t *testing.T) {
+	defer func(f func(context.Context, *aws.Fault) error) { revertFault = f }(revertFault)
+	r := newFakeReverter("")
+	j, path := openTestJournal(t, true)
+	defer j.close()
+	j.start(context.Background())
+
+	j.record(newFault("i-1", 10*time.Millisecond))
+	checkPending(t, path, 1)
+
+	// The run ends before the fault expires
+	if err := j.settle(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"i-1"}, r.instances()); diff != "" {
+		t.Fatal(diff)
+	}
+	checkPending(t, path, 0)
+}
+
+func TestFaultJournalInterrupt(t *testing.T) {
+	defer func(f func(context.Context, *aws.Fault) error) { revertFault = f }(revertFault)
+	r := newFakeReverter("i-1")
+	j, path := openTestJournal(t, true)
+	defer j.close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	j.start(ctx)
+
+	// The fault expires, but reverting it hangs
+	j.record(newFault("i-1", time.Millisecond))
+	<-r.started
+
+	// Faults of further chaos events are still recorded meanwhile
+	done := make(chan struct{})
+	go func() {
+		j.record(newFault("i-2", 0))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("recording blocked by reversal in progress")
+	}
+
+	// Interrupting the run cancels the reversal and rolls back all faults
+	cancel()
+	errc := make(chan error, 1)
+	go func() { errc <- j.settle(ctx, context.Canceled) }()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("rollback blocked by reversal in progress")
+	}
+	if diff := cmp.Diff([]string{"i-1", "i-2"}, r.instances()); diff != "" {
+		t.Fatal(diff)
+	}
+	checkPending(t, path, 0)
+}